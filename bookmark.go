@@ -0,0 +1,67 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Bookmarks returns a slice of ZFS bookmarks.
+// A filter argument may be passed to select the bookmarks of a dataset and its descendants, or empty string ("") may
+// be used to select all bookmarks.
+// Extra property names may be given to populate the Extra field of each bookmark from the same zfs command.
+func Bookmarks(filter string, extra ...string) ([]*Dataset, error) {
+	return defaultClient.Bookmarks(filter, extra...)
+}
+
+// BookmarksContext is like Bookmarks but includes a context.
+func BookmarksContext(ctx context.Context, filter string, extra ...string) ([]*Dataset, error) {
+	return defaultClient.BookmarksContext(ctx, filter, extra...)
+}
+
+// Bookmarks returns a slice of ZFS bookmarks.
+// A filter argument may be passed to select the bookmarks of a dataset and its descendants, or empty string ("") may
+// be used to select all bookmarks.
+// Extra property names may be given to populate the Extra field of each bookmark from the same zfs command.
+func (c *Client) Bookmarks(filter string, extra ...string) ([]*Dataset, error) {
+	return c.BookmarksContext(context.Background(), filter, extra...)
+}
+
+// BookmarksContext is like Bookmarks but includes a context.
+func (c *Client) BookmarksContext(ctx context.Context, filter string, extra ...string) ([]*Dataset, error) {
+	if err := c.require(ctx, FeatureBookmarks); err != nil {
+		return nil, err
+	}
+	return c.listByType(ctx, DatasetBookmark, filter, extra)
+}
+
+// Bookmark creates a bookmark of the receiving snapshot, using the specified name, and returns it.
+// A bookmark records the point in time of a snapshot and can be used as the base of an incremental send after the
+// snapshot is destroyed. Bookmarks can also be created from other bookmarks with OpenZFS 2.0 and later.
+// An error will be returned if the input dataset is not of snapshot or bookmark type.
+func (d *Dataset) Bookmark(name string) (*Dataset, error) {
+	return d.BookmarkContext(context.Background(), name)
+}
+
+// BookmarkContext is like Bookmark but includes a context.
+func (d *Dataset) BookmarkContext(ctx context.Context, name string) (*Dataset, error) {
+	switch d.Type {
+	case DatasetSnapshot:
+		if err := d.client.require(ctx, FeatureBookmarks); err != nil {
+			return nil, err
+		}
+	case DatasetBookmark:
+		if err := d.client.require(ctx, FeatureBookmarkCopy); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("can only bookmark snapshots and bookmarks")
+	}
+
+	i := strings.IndexAny(d.Name, "@#")
+	bookmark := d.Name[:i] + "#" + name
+	if err := d.client.zfs(ctx, "bookmark", d.Name, bookmark); err != nil {
+		return nil, err
+	}
+	return d.client.GetDatasetContext(ctx, bookmark)
+}
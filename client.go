@@ -0,0 +1,166 @@
+package zfs
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Client runs ZFS commands with its own configuration, so that different parts of a program may use different
+// timeouts, loggers or binaries.
+//
+// The zero value is ready to use and behaves like the package level functions: it uses the Runner set with
+// SetRunner, the Logger set with SetLogger and the zfs and zpool binaries found in PATH. Datasets and zpools
+// returned by a Client use the same Client for their methods.
+type Client struct {
+	// Runner specifies how commands are executed, including the Executor running them. If nil, Default() is used.
+	Runner *Runner
+
+	// Logger logs all commands including arguments before they are executed. If nil, the logger set with SetLogger
+	// is used.
+	Logger Logger
+
+	// ZFSPath and ZpoolPath are the names or paths of the zfs and zpool binaries. If empty, "zfs" and "zpool" are
+	// used.
+	ZFSPath   string
+	ZpoolPath string
+
+	// DisableJSON makes the Client parse the tab separated output of the ZFS tools even when they support JSON
+	// output.
+	DisableJSON bool
+
+	mu         sync.Mutex
+	version    *VersionInfo
+	versionErr error
+	// versionTransient is true if versionErr may be transient, such as when the host was unreachable.
+	versionTransient bool
+	versionRunner    *Runner
+}
+
+// defaultClient is used by the package level functions and by datasets and zpools not obtained from a Client.
+var defaultClient = &Client{}
+
+// The methods below accept a nil receiver, which is equivalent to the zero Client.
+
+func (c *Client) runner() *Runner {
+	if c == nil || c.Runner == nil {
+		return Default()
+	}
+	return c.Runner
+}
+
+// local reports whether the client runs commands as local processes, so that they can be connected by OS pipes.
+func (c *Client) local() bool {
+	switch c.runner().Executor.(type) {
+	case nil, *LocalExecutor:
+		return true
+	}
+	return false
+}
+
+func (c *Client) logger() Logger {
+	if c == nil || c.Logger == nil {
+		return logger
+	}
+	return c.Logger
+}
+
+func (c *Client) zfsPath() string {
+	if c == nil || c.ZFSPath == "" {
+		return "zfs"
+	}
+	return c.ZFSPath
+}
+
+func (c *Client) zpoolPath() string {
+	if c == nil || c.ZpoolPath == "" {
+		return "zpool"
+	}
+	return c.ZpoolPath
+}
+
+// zfs is a helper function to wrap typical calls to zfs that ignores stdout.
+func (c *Client) zfs(ctx context.Context, arg ...string) error {
+	_, err := c.zfsOutput(ctx, arg...)
+	return err
+}
+
+// zfsOutput is a helper function to wrap typical calls to zfs.
+func (c *Client) zfsOutput(ctx context.Context, arg ...string) ([][]string, error) {
+	cmd := command{Command: c.zfsPath(), client: c}
+	return cmd.RunContext(ctx, arg...)
+}
+
+// zpool is a helper function to wrap typical calls to zpool and ignores stdout.
+func (c *Client) zpool(ctx context.Context, arg ...string) error {
+	_, err := c.zpoolOutput(ctx, arg...)
+	return err
+}
+
+// zpoolOutput is a helper function to wrap typical calls to zpool.
+func (c *Client) zpoolOutput(ctx context.Context, arg ...string) ([][]string, error) {
+	cmd := command{Command: c.zpoolPath(), client: c}
+	return cmd.RunContext(ctx, arg...)
+}
+
+// listOutput runs `zfs list` for the properties in dsPropList followed by extra, selecting datasets with arg, and
+// returns one line per dataset.
+func (c *Client) listOutput(ctx context.Context, extra []string, arg ...string) ([][]string, error) {
+	props := dsPropList
+	options := dsPropListOptions
+	if len(extra) > 0 {
+		props = append(append([]string{}, dsPropList...), extra...)
+		options = strings.Join(props, ",")
+	}
+	if c.useJSON(ctx) {
+		data, err := c.jsonOutput(ctx, c.zfsPath(), append([]string{"list", "-j", "-p", "-o", options}, arg...)...)
+		if err != nil {
+			return nil, err
+		}
+		return jsonListLines(data, "datasets", props)
+	}
+	return c.zfsOutput(ctx, append([]string{"list", "-Hp", "-o", options}, arg...)...)
+}
+
+// getOutput runs `zfs get` or `zpool get`, depending on path, with arg and returns one line per property made of
+// fields. If fields is nil, the default name, property, value and source fields are printed.
+func (c *Client) getOutput(ctx context.Context, path string, fields []string, arg ...string) ([][]string, error) {
+	if fields != nil {
+		arg = append([]string{"-o", strings.Join(fields, ",")}, arg...)
+	} else {
+		fields = getFields
+	}
+	if c.useJSON(ctx) {
+		member := "datasets"
+		if path == c.zpoolPath() {
+			member = "pools"
+		}
+		data, err := c.jsonOutput(ctx, path, append([]string{"get", "-j", "-p"}, arg...)...)
+		if err != nil {
+			return nil, err
+		}
+		return jsonGetLines(data, member, fields)
+	}
+	cmd := command{Command: path, client: c}
+	return cmd.RunContext(ctx, append([]string{"get", "-Hp"}, arg...)...)
+}
+
+// datasets creates a Dataset for each dataset in the output of listOutput.
+func (c *Client) datasets(out [][]string, extra []string) ([]*Dataset, error) {
+	var datasets []*Dataset
+
+	name := ""
+	var ds *Dataset
+	for _, line := range out {
+		if name != line[0] {
+			name = line[0]
+			ds = &Dataset{Name: name, client: c}
+			datasets = append(datasets, ds)
+		}
+		if err := ds.parseLine(line, extra); err != nil {
+			return nil, err
+		}
+	}
+
+	return datasets, nil
+}
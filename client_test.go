@@ -0,0 +1,55 @@
+package zfs_test
+
+import (
+	"strings"
+	"testing"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+	"github.com/mistifyio/go-zfs/v4/zfstest"
+)
+
+type recordingLogger struct {
+	cmds []string
+}
+
+func (l *recordingLogger) Log(cmd []string) {
+	l.cmds = append(l.cmds, strings.Join(cmd[1:], " "))
+}
+
+func TestClient(t *testing.T) {
+	a := &zfs.Client{Runner: &zfs.Runner{Executor: zfstest.NewEmulator()}}
+	logger := &recordingLogger{}
+	b := &zfs.Client{
+		Runner:  &zfs.Runner{Executor: zfstest.NewEmulator()},
+		Logger:  logger,
+		ZFSPath: "/opt/zfs/bin/zfs",
+	}
+
+	for _, c := range []*zfs.Client{a, b} {
+		_, err := c.CreateZpool("test", nil, "/dev/null")
+		ok(t, err)
+	}
+
+	fs, err := a.CreateFilesystem("test/only-a", nil)
+	ok(t, err)
+
+	// Methods of datasets returned by a client use that client.
+	_, err = fs.Snapshot("snap", false)
+	ok(t, err)
+	snapshots, err := a.Snapshots("test")
+	ok(t, err)
+	equals(t, 1, len(snapshots))
+
+	_, err = b.GetDataset("test/only-a")
+	nok(t, err)
+	snapshots, err = b.Snapshots("test")
+	ok(t, err)
+	equals(t, 0, len(snapshots))
+
+	assert(t, len(logger.cmds) > 0, "client logger was not used")
+	for _, cmd := range logger.cmds {
+		if strings.HasPrefix(cmd, "START") {
+			assert(t, strings.HasPrefix(cmd, "START zpool ") || strings.HasPrefix(cmd, "START /opt/zfs/bin/zfs "), "unexpected command: %s", cmd)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Compression is the value of the compression property.
+type Compression string
+
+// Compression algorithms. Algorithms taking a level can be built with GzipLevel, ZstdLevel and ZstdFastLevel.
+const (
+	CompressionOn       Compression = "on"
+	CompressionOff      Compression = "off"
+	CompressionLZ4      Compression = "lz4"
+	CompressionLZJB     Compression = "lzjb"
+	CompressionZLE      Compression = "zle"
+	CompressionGzip     Compression = "gzip"
+	CompressionZstd     Compression = "zstd"
+	CompressionZstdFast Compression = "zstd-fast"
+)
+
+// GzipLevel returns the gzip compression with the given level, from 1 to 9.
+func GzipLevel(level int) Compression {
+	return Compression("gzip-" + strconv.Itoa(level))
+}
+
+// ZstdLevel returns the zstd compression with the given level, from 1 to 19.
+func ZstdLevel(level int) Compression {
+	return Compression("zstd-" + strconv.Itoa(level))
+}
+
+// ZstdFastLevel returns the zstd-fast compression with the given level, from 1 to 10, a multiple of 10 up to 100,
+// 500 or 1000.
+func ZstdFastLevel(level int) Compression {
+	return Compression("zstd-fast-" + strconv.Itoa(level))
+}
+
+func validZstdFastLevel(level int) bool {
+	return level > 0 && (level <= 10 || level <= 100 && level%10 == 0) || level == 500 || level == 1000
+}
+
+// Validate returns an error matching ErrInvalidProperty if c is not a compression algorithm known to ZFS.
+func (c Compression) Validate() error {
+	switch c {
+	case CompressionOn, CompressionOff, CompressionLZ4, CompressionLZJB, CompressionZLE, CompressionGzip,
+		CompressionZstd, CompressionZstdFast:
+		return nil
+	}
+	s := string(c)
+	for _, alg := range []struct {
+		prefix string
+		valid  func(int) bool
+	}{
+		{"gzip-", func(l int) bool { return l >= 1 && l <= 9 }},
+		{"zstd-fast-", validZstdFastLevel},
+		{"zstd-", func(l int) bool { return l >= 1 && l <= 19 }},
+	} {
+		if !strings.HasPrefix(s, alg.prefix) {
+			continue
+		}
+		if level, err := strconv.Atoi(s[len(alg.prefix):]); err == nil && alg.valid(level) {
+			return nil
+		}
+		return fmt.Errorf("%w: invalid %slevel for compression: %q", ErrInvalidProperty, alg.prefix, s)
+	}
+	return fmt.Errorf("%w: unknown compression: %q", ErrInvalidProperty, s)
+}
+
+// ParseCompression parses the value of the compression property.
+func ParseCompression(s string) (Compression, error) {
+	c := Compression(s)
+	return c, c.Validate()
+}
+
+// Limits of the recordsize property.
+const (
+	MinRecordSize RecordSize = 512
+	MaxRecordSize RecordSize = 16 << 20
+)
+
+// RecordSize is the value of the recordsize property, in bytes.
+type RecordSize uint64
+
+// Validate returns an error matching ErrInvalidProperty if r is not a power of two between MinRecordSize and
+// MaxRecordSize.
+func (r RecordSize) Validate() error {
+	if r < MinRecordSize || r > MaxRecordSize || r&(r-1) != 0 {
+		return fmt.Errorf("%w: recordsize must be a power of 2 from 512B to 16M, got %d", ErrInvalidProperty, r)
+	}
+	return nil
+}
+
+func (r RecordSize) String() string {
+	return strconv.FormatUint(uint64(r), 10)
+}
+
+// ParseRecordSize parses the value of the recordsize property, as returned by GetProperty or in the form accepted by
+// ParseSize, such as "128K".
+func ParseRecordSize(s string) (RecordSize, error) {
+	n, err := ParseSize(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid recordsize: %q", ErrInvalidProperty, s)
+	}
+	r := RecordSize(n)
+	return r, r.Validate()
+}
+
+// OnOff is the value of a boolean property such as atime.
+type OnOff string
+
+// Values of boolean properties.
+const (
+	On  OnOff = "on"
+	Off OnOff = "off"
+)
+
+// Validate returns an error matching ErrInvalidProperty if o is neither On nor Off.
+func (o OnOff) Validate() error {
+	if o != On && o != Off {
+		return fmt.Errorf("%w: must be on or off, got %q", ErrInvalidProperty, string(o))
+	}
+	return nil
+}
+
+// ParseOnOff parses the value of a boolean property.
+func ParseOnOff(s string) (OnOff, error) {
+	o := OnOff(s)
+	return o, o.Validate()
+}
+
+// SyncMode is the value of the sync property.
+type SyncMode string
+
+// Values of the sync property.
+const (
+	SyncStandard SyncMode = "standard"
+	SyncAlways   SyncMode = "always"
+	SyncDisabled SyncMode = "disabled"
+)
+
+// Validate returns an error matching ErrInvalidProperty if s is not a known sync mode.
+func (s SyncMode) Validate() error {
+	switch s {
+	case SyncStandard, SyncAlways, SyncDisabled:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown sync mode: %q", ErrInvalidProperty, string(s))
+}
+
+// ParseSyncMode parses the value of the sync property.
+func ParseSyncMode(s string) (SyncMode, error) {
+	m := SyncMode(s)
+	return m, m.Validate()
+}
+
+// Xattr is the value of the xattr property.
+type Xattr string
+
+// Values of the xattr property.
+const (
+	XattrOn  Xattr = "on"
+	XattrOff Xattr = "off"
+	XattrSA  Xattr = "sa"
+	XattrDir Xattr = "dir"
+)
+
+// Validate returns an error matching ErrInvalidProperty if x is not a known xattr mode.
+func (x Xattr) Validate() error {
+	switch x {
+	case XattrOn, XattrOff, XattrSA, XattrDir:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown xattr mode: %q", ErrInvalidProperty, string(x))
+}
+
+// ParseXattr parses the value of the xattr property.
+func ParseXattr(s string) (Xattr, error) {
+	x := Xattr(s)
+	return x, x.Validate()
+}
+
+// ParseCopies parses the value of the copies property, from 1 to 3.
+func ParseCopies(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 3 {
+		return 0, fmt.Errorf("%w: copies must be 1, 2 or 3, got %q", ErrInvalidProperty, s)
+	}
+	return n, nil
+}
+
+// parseLimit parses the value of a quota or reservation property, where none and 0 mean no limit.
+func parseLimit(s string) (*Size, error) {
+	n, err := ParseSize(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid size: %q", ErrInvalidProperty, s)
+	}
+	return &n, nil
+}
+
+// DatasetProperties holds typed values of common ZFS properties. Zero values are left unset, so that ZFS applies its
+// defaults or inherits them from the parent dataset.
+type DatasetProperties struct {
+	Compression Compression
+	// RecordSize only applies to filesystems.
+	RecordSize RecordSize
+	Atime      OnOff
+	Sync       SyncMode
+	Xattr      Xattr
+	// Copies is the number of copies of data, from 1 to 3.
+	Copies int
+
+	// Quota, RefQuota, Reservation and RefReservation are limits in bytes. A pointer to 0 removes the limit.
+	Quota          *Size
+	RefQuota       *Size
+	Reservation    *Size
+	RefReservation *Size
+}
+
+// datasetPropertyNames are the properties held by DatasetProperties.
+var datasetPropertyNames = []string{"compression", "recordsize", "atime", "sync", "xattr", "copies", "quota", "refquota", "reservation", "refreservation"}
+
+// Validate returns an error matching ErrInvalidProperty if any of the set properties is invalid.
+func (p *DatasetProperties) Validate() error {
+	_, err := p.Map()
+	return err
+}
+
+// Map validates the set properties and returns them as a map suitable for CreateFilesystem, CreateVolume or
+// SetProperties.
+func (p *DatasetProperties) Map() (map[string]string, error) {
+	props := map[string]string{}
+	if p.Compression != "" {
+		if err := p.Compression.Validate(); err != nil {
+			return nil, fmt.Errorf("compression: %w", err)
+		}
+		props["compression"] = string(p.Compression)
+	}
+	if p.RecordSize != 0 {
+		if err := p.RecordSize.Validate(); err != nil {
+			return nil, fmt.Errorf("recordsize: %w", err)
+		}
+		props["recordsize"] = p.RecordSize.String()
+	}
+	if p.Atime != "" {
+		if err := p.Atime.Validate(); err != nil {
+			return nil, fmt.Errorf("atime: %w", err)
+		}
+		props["atime"] = string(p.Atime)
+	}
+	if p.Sync != "" {
+		if err := p.Sync.Validate(); err != nil {
+			return nil, fmt.Errorf("sync: %w", err)
+		}
+		props["sync"] = string(p.Sync)
+	}
+	if p.Xattr != "" {
+		if err := p.Xattr.Validate(); err != nil {
+			return nil, fmt.Errorf("xattr: %w", err)
+		}
+		props["xattr"] = string(p.Xattr)
+	}
+	if p.Copies != 0 {
+		if p.Copies < 1 || p.Copies > 3 {
+			return nil, fmt.Errorf("copies: %w: must be 1, 2 or 3, got %d", ErrInvalidProperty, p.Copies)
+		}
+		props["copies"] = strconv.Itoa(p.Copies)
+	}
+	for name, limit := range map[string]*Size{
+		"quota":          p.Quota,
+		"refquota":       p.RefQuota,
+		"reservation":    p.Reservation,
+		"refreservation": p.RefReservation,
+	} {
+		switch {
+		case limit == nil:
+		case *limit == 0:
+			props[name] = "none"
+		default:
+			props[name] = strconv.FormatUint(uint64(*limit), 10)
+		}
+	}
+	return props, nil
+}
+
+// ParseDatasetProperties parses the properties held by DatasetProperties from props, as returned by
+// Dataset.GetProperties. Properties missing from props, or which do not apply to the dataset, are left unset.
+func ParseDatasetProperties(props map[string]Property) (*DatasetProperties, error) {
+	p := &DatasetProperties{}
+	var err error
+	for _, name := range datasetPropertyNames {
+		prop, ok := props[name]
+		if !ok || prop.Value == "-" {
+			continue
+		}
+		switch v := prop.Value; name {
+		case "compression":
+			p.Compression, err = ParseCompression(v)
+		case "recordsize":
+			p.RecordSize, err = ParseRecordSize(v)
+		case "atime":
+			p.Atime, err = ParseOnOff(v)
+		case "sync":
+			p.Sync, err = ParseSyncMode(v)
+		case "xattr":
+			p.Xattr, err = ParseXattr(v)
+		case "copies":
+			p.Copies, err = ParseCopies(v)
+		case "quota":
+			p.Quota, err = parseLimit(v)
+		case "refquota":
+			p.RefQuota, err = parseLimit(v)
+		case "reservation":
+			p.Reservation, err = parseLimit(v)
+		case "refreservation":
+			p.RefReservation, err = parseLimit(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return p, nil
+}
+
+// GetDatasetProperties returns the typed values of the properties held by DatasetProperties for the receiving
+// dataset.
+func (d *Dataset) GetDatasetProperties() (*DatasetProperties, error) {
+	return d.GetDatasetPropertiesContext(context.Background())
+}
+
+// GetDatasetPropertiesContext is like GetDatasetProperties but includes a context.
+func (d *Dataset) GetDatasetPropertiesContext(ctx context.Context) (*DatasetProperties, error) {
+	props, err := d.GetPropertiesContext(ctx, datasetPropertyNames...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDatasetProperties(props)
+}
@@ -0,0 +1,113 @@
+package zfs
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompressionValidate(t *testing.T) {
+	for c, valid := range map[Compression]bool{
+		CompressionLZ4:    true,
+		GzipLevel(9):      true,
+		ZstdLevel(19):     true,
+		ZstdFastLevel(50): true,
+		ZstdFastLevel(0):  false,
+		ZstdFastLevel(55): false,
+		GzipLevel(10):     false,
+		"zstd-x":          false,
+		"brotli":          false,
+	} {
+		err := c.Validate()
+		if valid && err != nil {
+			t.Errorf("%q: unexpected error: %v", c, err)
+		}
+		if !valid && !errors.Is(err, ErrInvalidProperty) {
+			t.Errorf("%q: wanted ErrInvalidProperty, got %v", c, err)
+		}
+	}
+}
+
+func TestDatasetPropertiesMap(t *testing.T) {
+	none, gig := Size(0), Gibibyte
+	p := &DatasetProperties{
+		Compression: ZstdLevel(3),
+		RecordSize:  1 << 20,
+		Atime:       Off,
+		Sync:        SyncAlways,
+		Xattr:       XattrSA,
+		Copies:      2,
+		Quota:       &gig,
+		Reservation: &none,
+	}
+	props, err := p.Map()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"compression": "zstd-3",
+		"recordsize":  "1048576",
+		"atime":       "off",
+		"sync":        "always",
+		"xattr":       "sa",
+		"copies":      "2",
+		"quota":       "1073741824",
+		"reservation": "none",
+	}
+	if !reflect.DeepEqual(props, want) {
+		t.Fatalf("unexpected properties: %v", props)
+	}
+
+	for _, tt := range []struct {
+		invalid *DatasetProperties
+		name    string
+	}{
+		{&DatasetProperties{Compression: "gzip-10"}, "compression"},
+		{&DatasetProperties{RecordSize: 1000}, "recordsize"},
+		{&DatasetProperties{RecordSize: 32 << 20}, "recordsize"},
+		{&DatasetProperties{Atime: "yes"}, "atime"},
+		{&DatasetProperties{Sync: "sometimes"}, "sync"},
+		{&DatasetProperties{Xattr: "maybe"}, "xattr"},
+		{&DatasetProperties{Copies: 4}, "copies"},
+	} {
+		err := tt.invalid.Validate()
+		if !errors.Is(err, ErrInvalidProperty) {
+			t.Errorf("%+v: wanted ErrInvalidProperty, got %v", tt.invalid, err)
+		} else if !strings.HasPrefix(err.Error(), tt.name+": ") {
+			t.Errorf("%+v: wanted the error to name %s, got %v", tt.invalid, tt.name, err)
+		}
+	}
+}
+
+func TestParseDatasetProperties(t *testing.T) {
+	p, err := ParseDatasetProperties(map[string]Property{
+		"atime":    {Value: "off"},
+		"copies":   {Value: "2"},
+		"quota":    {Value: "none"},
+		"sync":     {Value: "-"},
+		"readonly": {Value: "on"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Atime != Off || p.Copies != 2 || p.Quota == nil || *p.Quota != 0 || p.Sync != "" {
+		t.Fatalf("unexpected properties: %+v", p)
+	}
+
+	for name, value := range map[string]string{
+		"atime":       "yes",
+		"sync":        "sometimes",
+		"copies":      "4",
+		"recordsize":  "1000",
+		"compression": "gzip-10",
+		"quota":       "lots",
+	} {
+		_, err := ParseDatasetProperties(map[string]Property{name: {Value: value}})
+		if !errors.Is(err, ErrInvalidProperty) {
+			t.Errorf("%s=%s: wanted ErrInvalidProperty, got %v", name, value, err)
+		} else if !strings.HasPrefix(err.Error(), name+": ") {
+			t.Errorf("%s=%s: wanted the error to name %s, got %v", name, value, name, err)
+		}
+	}
+}
@@ -1,18 +1,152 @@
 package zfs
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
+// ErrorCode classifies the reason a `zfs` or `zpool` command failed.
+type ErrorCode int
+
+// Error codes derived from the output of failed commands.
+const (
+	CodeUnknown ErrorCode = iota
+	CodeNotFound
+	CodeAlreadyExists
+	CodeBusy
+	CodePermissionDenied
+	CodeNoSpace
+	CodeHasChildren
+	CodeHasClones
+	CodeInvalidProperty
+	CodePoolUnavailable
+	CodePrivilegeEscalation
+	CodeHeld
+)
+
+// Sentinel errors matching, using errors.Is, an *Error with the corresponding Code.
+var (
+	ErrNotFound         = errors.New("dataset or pool does not exist")
+	ErrAlreadyExists    = errors.New("dataset or pool already exists")
+	ErrBusy             = errors.New("dataset or pool is busy")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrNoSpace          = errors.New("out of space")
+	ErrHasChildren      = errors.New("dataset has children")
+	ErrHasClones        = errors.New("dataset has dependent clones")
+	ErrInvalidProperty  = errors.New("invalid property or property value")
+	ErrPoolUnavailable  = errors.New("pool is unavailable")
+
+	// ErrPrivilegeEscalation matches an error returned when the privilege escalation tool configured with
+	// Runner.Prefix refused to run the command, as opposed to the command itself failing.
+	ErrPrivilegeEscalation = errors.New("privilege escalation failed")
+
+	// ErrHeld matches an error returned when destroying a snapshot fails because of a user hold placed with
+	// Dataset.Hold. Such errors also match ErrBusy, which the ZFS tools report them as.
+	ErrHeld = errors.New("snapshot is held")
+)
+
+var codeErrors = map[ErrorCode]error{
+	CodeNotFound:            ErrNotFound,
+	CodeAlreadyExists:       ErrAlreadyExists,
+	CodeBusy:                ErrBusy,
+	CodePermissionDenied:    ErrPermissionDenied,
+	CodeNoSpace:             ErrNoSpace,
+	CodeHasChildren:         ErrHasChildren,
+	CodeHasClones:           ErrHasClones,
+	CodeInvalidProperty:     ErrInvalidProperty,
+	CodePoolUnavailable:     ErrPoolUnavailable,
+	CodePrivilegeEscalation: ErrPrivilegeEscalation,
+	CodeHeld:                ErrHeld,
+}
+
+// String returns the description of the error code.
+func (c ErrorCode) String() string {
+	if err, ok := codeErrors[c]; ok {
+		return err.Error()
+	}
+	return "unknown error"
+}
+
+// Fragments of the messages printed by the ZFS tools of the supported platforms and versions, checked in order.
+var codeMessages = []struct {
+	code      ErrorCode
+	fragments []string
+}{
+	{CodeHasClones, []string{"has dependent clones", "clones of previous snapshots exist"}},
+	{CodeHasChildren, []string{"has children"}},
+	{CodeBusy, []string{"is busy", "device busy", "resource busy"}},
+	{CodePoolUnavailable, []string{"pool is unavailable", "is currently suspended", "pool is suspended", "currently unavailable", "pool is faulted", "insufficient replicas"}},
+	{CodeNoSpace, []string{"out of space", "no space left", "insufficient space", "greater than available space", "quota exceeded"}},
+	{CodePermissionDenied, []string{"permission denied", "operation not permitted", "insufficient privileges", "must be root", "do not have permission"}},
+	{CodeInvalidProperty, []string{"invalid property", "bad property", "is readonly", "must be one of", "bad numeric value", "does not apply to", "must be power of 2", "must be an absolute path", "invalid value"}},
+	{CodeAlreadyExists, []string{"already exists", "exists\nmust specify -f", "destination already exists"}},
+	{CodeNotFound, []string{"does not exist", "no such pool", "no such dataset", "could not find any snapshots", "no such tag"}},
+}
+
+// classify derives the ErrorCode of a failed command from its standard error.
+func classify(stderr string) ErrorCode {
+	stderr = strings.ToLower(stderr)
+	for _, m := range codeMessages {
+		for _, fragment := range m.fragments {
+			if strings.Contains(stderr, fragment) {
+				return m.code
+			}
+		}
+	}
+	return CodeUnknown
+}
+
 // Error is an error which is returned when the `zfs` or `zpool` shell
 // commands return with a non-zero exit code.
 type Error struct {
 	Err    error
 	Debug  string
 	Stderr string
+
+	// Code classifies the failure based on Stderr, and may be compared with the sentinel errors using errors.Is.
+	Code ErrorCode
+
+	// ExitCode is the exit code of the command, or -1 if it did not exit normally or could not be started.
+	ExitCode int
+}
+
+// newError returns the Error for a command which failed with err. If the command was run through the privilege
+// escalation tool at prefix, failures of the tool are reported with CodePrivilegeEscalation.
+func newError(err error, debug, stderr, prefix string) *Error {
+	e := &Error{
+		Err:      err,
+		Debug:    debug,
+		Stderr:   stderr,
+		ExitCode: -1,
+	}
+	var exit interface{ ExitCode() int }
+	if errors.As(err, &exit) {
+		e.ExitCode = exit.ExitCode()
+	}
+	if prefix != "" && escalationFailed(prefix, stderr) {
+		e.Code = CodePrivilegeEscalation
+	} else {
+		e.Code = classify(stderr)
+	}
+	return e
 }
 
 // Error returns the string representation of an Error.
 func (e Error) Error() string {
 	return fmt.Sprintf("%s: %q => %s", e.Err, e.Debug, e.Stderr)
 }
+
+// Is reports whether target is the sentinel error for the error's Code.
+func (e Error) Is(target error) bool {
+	if e.Code == CodeHeld && target == ErrBusy {
+		return true
+	}
+	err, ok := codeErrors[e.Code]
+	return ok && target == err
+}
+
+// Unwrap returns the underlying error of the command.
+func (e Error) Unwrap() error {
+	return e.Err
+}
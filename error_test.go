@@ -35,3 +35,72 @@ func TestError(t *testing.T) {
 		}
 	}
 }
+
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func (e exitError) ExitCode() int {
+	return int(e)
+}
+
+func TestErrorClassification(t *testing.T) {
+	for _, tt := range []struct {
+		stderr string
+		code   ErrorCode
+		err    error
+	}{
+		{"cannot open 'tank/nope': dataset does not exist\n", CodeNotFound, ErrNotFound},
+		{"cannot open 'nope': no such pool\n", CodeNotFound, ErrNotFound},
+		{"could not find any snapshots to destroy; check snapshot names.\n", CodeNotFound, ErrNotFound},
+		{"cannot release hold from snapshot 'tank/fs@snap': no such tag on this dataset\n", CodeNotFound, ErrNotFound},
+		{"cannot create 'tank/fs': dataset already exists\n", CodeAlreadyExists, ErrAlreadyExists},
+		{"cannot hold snapshot 'tank/fs@snap': tag already exists on this dataset\n", CodeAlreadyExists, ErrAlreadyExists},
+		{"cannot receive new filesystem stream: destination 'tank/fs' exists\nmust specify -F to overwrite it\n", CodeAlreadyExists, ErrAlreadyExists},
+		{"cannot destroy 'tank/fs': dataset is busy\n", CodeBusy, ErrBusy},
+		{"cannot unmount '/tank/fs': pool or dataset is busy\n", CodeBusy, ErrBusy},
+		{"umount: /tank/fs: target is busy.\ncannot unmount '/tank/fs': umount failed\n", CodeBusy, ErrBusy},
+		{"cannot create 'tank/fs': permission denied\n", CodePermissionDenied, ErrPermissionDenied},
+		{"cannot set property for 'tank/fs': out of space\n", CodeNoSpace, ErrNoSpace},
+		{"cannot create 'tank/vol': volume size is greater than available space\n", CodeNoSpace, ErrNoSpace},
+		{"cannot destroy 'tank/fs': filesystem has children\nuse '-r' to destroy the following datasets:\ntank/fs@snap\n", CodeHasChildren, ErrHasChildren},
+		{"cannot destroy 'tank/fs@snap': snapshot has dependent clones\nuse '-R' to destroy the following datasets:\ntank/clone\n", CodeHasClones, ErrHasClones},
+		{"bad property list: invalid property 'foobarbaz'\nusage:\n\tget [-rHp] ...\n", CodeInvalidProperty, ErrInvalidProperty},
+		{"cannot set property for 'tank': 'used' is readonly\n", CodeInvalidProperty, ErrInvalidProperty},
+		{"cannot set property for 'tank': 'compression' must be one of 'on | off | lzjb'\n", CodeInvalidProperty, ErrInvalidProperty},
+		{"cannot open 'tank': pool I/O is currently suspended\n", CodePoolUnavailable, ErrPoolUnavailable},
+		{"cannot import 'tank': one or more devices is currently unavailable\n", CodePoolUnavailable, ErrPoolUnavailable},
+		{"internal error: Invalid argument\n", CodeUnknown, nil},
+	} {
+		e := newError(exitError(1), "zfs", tt.stderr, "")
+		if e.Code != tt.code {
+			t.Errorf("%q: wanted code %v, got %v", tt.stderr, tt.code, e.Code)
+		}
+		if e.ExitCode != 1 {
+			t.Errorf("%q: wanted exit code 1, got %d", tt.stderr, e.ExitCode)
+		}
+		var err error = e
+		for _, sentinel := range codeErrors {
+			if got := errors.Is(err, sentinel); got != (sentinel == tt.err) {
+				t.Errorf("%q: errors.Is(err, %q) = %v", tt.stderr, sentinel, got)
+			}
+		}
+	}
+}
+
+func TestErrorExitCode(t *testing.T) {
+	e := newError(errors.New("exec: \"zfs\": executable file not found in $PATH"), "zfs", "", "")
+	if e.ExitCode != -1 {
+		t.Fatalf("wanted exit code -1, got %d", e.ExitCode)
+	}
+	if e.Code != CodeUnknown {
+		t.Fatalf("wanted unknown code, got %v", e.Code)
+	}
+
+	e = newError(exitError(1), "sudo -n zfs list", "sudo: a password is required\n", "sudo")
+	if !errors.Is(e, ErrPrivilegeEscalation) || errors.Is(e, ErrPermissionDenied) {
+		t.Fatalf("wanted privilege escalation error, got %v", e.Code)
+	}
+}
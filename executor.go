@@ -0,0 +1,127 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Cmd describes a single invocation of one of the ZFS command line tools.
+type Cmd struct {
+	// Path is the command to run, such as "zfs" or "zpool".
+	Path string
+
+	// Args holds the command line arguments, not including the command itself.
+	Args []string
+
+	// Stdin, Stdout and Stderr are connected to the standard streams of the command. A nil Stdin reads nothing, a
+	// nil Stdout or Stderr discards the output.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Argv returns the full argument vector of the command, starting with Path.
+func (c *Cmd) Argv() []string {
+	return append([]string{c.Path}, c.Args...)
+}
+
+// Executor runs commands on behalf of a Runner.
+//
+// Exec must run cmd to completion, or until ctx is done, and return a non-nil error if the command could not be
+// started or did not exit successfully. Errors for commands that ran but failed should implement
+// `ExitCode() int`, as *exec.ExitError does.
+type Executor interface {
+	Exec(ctx context.Context, cmd *Cmd) error
+}
+
+// LocalExecutor runs commands as child processes of the current process.
+// It is used by a Runner without an Executor.
+type LocalExecutor struct {
+	// Grace specifies the time waited after signaling the running process with SIGTERM, once ctx is done, before it
+	// is forcefully killed with SIGKILL.
+	Grace time.Duration
+}
+
+// Exec runs cmd as a local process. Like exec.Command, a Path without path separators is resolved using the PATH
+// environment variable, and cmd.Path is updated with the result.
+func (e *LocalExecutor) Exec(ctx context.Context, cmd *Cmd) error {
+	c := exec.CommandContext(ctx, cmd.Path, cmd.Args...)
+	c.Cancel = func() error {
+		return c.Process.Signal(syscall.SIGTERM)
+	}
+	c.WaitDelay = e.Grace
+	c.Stdin = cmd.Stdin
+	c.Stdout = cmd.Stdout
+	c.Stderr = cmd.Stderr
+
+	cmd.Path = c.Path
+	return c.Run()
+}
+
+// RemoteExecutor runs commands through a transport command such as ssh, which receives the whole command line as a
+// single shell-quoted argument. The standard streams of the commands are connected to those of the transport, so
+// sends and receives stream through it.
+//
+// Cancellation, once ctx is done or the Runner's Timeout expires, only signals the local transport process. Whether
+// the remote command is stopped depends on the transport: ssh without a terminal closes the session, but the remote
+// command may keep running until it next writes to it.
+type RemoteExecutor struct {
+	// Transport is the command line of the transport, for example []string{"ssh", "-T", "storage1", "--"}.
+	Transport []string
+
+	// Executor runs the transport command. If nil, a LocalExecutor using Grace is used.
+	Executor Executor
+
+	// Grace specifies the time waited after signaling the transport process with SIGTERM, once ctx is done, before
+	// it is forcefully killed with SIGKILL. It is ignored when Executor is set.
+	Grace time.Duration
+}
+
+// Exec runs cmd through the transport.
+func (e *RemoteExecutor) Exec(ctx context.Context, cmd *Cmd) error {
+	if len(e.Transport) == 0 {
+		return errors.New("remote executor has no transport")
+	}
+
+	args := make([]string, 0, len(e.Transport))
+	args = append(args, e.Transport[1:]...)
+	args = append(args, shellQuote(cmd.Argv()))
+	transport := &Cmd{
+		Path:   e.Transport[0],
+		Args:   args,
+		Stdin:  cmd.Stdin,
+		Stdout: cmd.Stdout,
+		Stderr: cmd.Stderr,
+	}
+
+	executor := e.Executor
+	if executor == nil {
+		executor = &LocalExecutor{Grace: e.Grace}
+	}
+	return executor.Exec(ctx, transport)
+}
+
+// shellQuote joins argv into a command line which a POSIX shell splits back into the same arguments.
+func shellQuote(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		safe := arg != ""
+		for _, r := range arg {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%+=:,./_-", r)) {
+				safe = false
+				break
+			}
+		}
+		if safe {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
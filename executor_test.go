@@ -0,0 +1,73 @@
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellQuote(t *testing.T) {
+	for _, tt := range []struct {
+		argv []string
+		want string
+	}{
+		{argv: []string{"zfs", "list", "-Hp", "tank/fs@snap"}, want: "zfs list -Hp tank/fs@snap"},
+		{argv: []string{"zfs", "set", "com.example:note=a b", "tank"}, want: "zfs set 'com.example:note=a b' tank"},
+		{argv: []string{"echo", "it's", "", "$HOME"}, want: `echo 'it'\''s' '' '$HOME'`},
+	} {
+		if got := shellQuote(tt.argv); got != tt.want {
+			t.Errorf("shellQuote(%q): wanted %s, got %s", tt.argv, tt.want, got)
+		}
+	}
+}
+
+func TestRemoteExecutor(t *testing.T) {
+	e := &RemoteExecutor{Transport: []string{"sh", "-c"}}
+	ctx := context.Background()
+
+	var stdout bytes.Buffer
+	err := e.Exec(ctx, &Cmd{Path: "printf", Args: []string{"%s|", "a b", "it's", "$HOME", ""}, Stdout: &stdout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "a b|it's|$HOME||"; got != want {
+		t.Fatalf("wanted output %q, got %q", want, got)
+	}
+
+	stdout.Reset()
+	err = e.Exec(ctx, &Cmd{Path: "cat", Stdin: strings.NewReader("stream data"), Stdout: &stdout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); got != "stream data" {
+		t.Fatalf("stdin was not streamed, got %q", got)
+	}
+
+	var stderr bytes.Buffer
+	err = e.Exec(ctx, &Cmd{Path: "sh", Args: []string{"-c", "echo oops >&2; exit 3"}, Stderr: &stderr})
+	var exit interface{ ExitCode() int }
+	if !errors.As(err, &exit) || exit.ExitCode() != 3 {
+		t.Fatalf("wanted exit code 3, got %v", err)
+	}
+	if stderr.String() != "oops\n" {
+		t.Fatalf("unexpected stderr: %q", stderr.String())
+	}
+}
+
+func TestRemoteExecutorGrace(t *testing.T) {
+	// The transport ignores SIGTERM, so that only the SIGKILL sent after Grace stops it.
+	e := &RemoteExecutor{Transport: []string{"sh", "-c", "trap '' TERM; sleep 5; :", "sh"}, Grace: 100 * time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := e.Exec(ctx, &Cmd{Path: "true"}); err == nil {
+		t.Fatal("wanted an error for a killed transport")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("transport was not killed after Grace, took %v", elapsed)
+	}
+}
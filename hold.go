@@ -0,0 +1,91 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// holdTimeLayout is the layout of the timestamps printed by `zfs holds`.
+const holdTimeLayout = "Mon Jan _2 15:04 2006"
+
+// Hold is a user hold on a snapshot, which prevents it from being destroyed.
+type Hold struct {
+	// Name is the name of the held snapshot.
+	Name string
+	Tag  string
+	// Timestamp is when the hold was placed, to the minute.
+	Timestamp time.Time
+}
+
+// Hold places a user hold with the given tag on the receiving snapshot, which prevents it from being destroyed until
+// the hold is released. If recursive is true, snapshots with the same name of all descendent datasets are also held.
+// An error will be returned if the input dataset is not of snapshot type.
+func (d *Dataset) Hold(tag string, recursive bool) error {
+	return d.HoldContext(context.Background(), tag, recursive)
+}
+
+// HoldContext is like Hold but includes a context.
+func (d *Dataset) HoldContext(ctx context.Context, tag string, recursive bool) error {
+	return d.holdOrRelease(ctx, "hold", tag, recursive)
+}
+
+// Release removes the user hold with the given tag from the receiving snapshot. If recursive is true, the hold is
+// also removed from snapshots with the same name of all descendent datasets. A snapshot marked for deferred
+// deletion is destroyed when its last hold is released.
+// An error will be returned if the input dataset is not of snapshot type.
+func (d *Dataset) Release(tag string, recursive bool) error {
+	return d.ReleaseContext(context.Background(), tag, recursive)
+}
+
+// ReleaseContext is like Release but includes a context.
+func (d *Dataset) ReleaseContext(ctx context.Context, tag string, recursive bool) error {
+	return d.holdOrRelease(ctx, "release", tag, recursive)
+}
+
+func (d *Dataset) holdOrRelease(ctx context.Context, subcommand, tag string, recursive bool) error {
+	if d.Type != DatasetSnapshot {
+		return errors.New("can only " + subcommand + " snapshots")
+	}
+	args := []string{subcommand}
+	if recursive {
+		args = append(args, "-r")
+	}
+	args = append(args, tag, d.Name)
+	return d.client.zfs(ctx, args...)
+}
+
+// Holds returns the user holds on the receiving snapshot.
+// An error will be returned if the input dataset is not of snapshot type.
+func (d *Dataset) Holds() ([]Hold, error) {
+	return d.HoldsContext(context.Background())
+}
+
+// HoldsContext is like Holds but includes a context.
+func (d *Dataset) HoldsContext(ctx context.Context) ([]Hold, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only list the holds of snapshots")
+	}
+	out, err := d.client.zfsOutput(ctx, "holds", "-H", d.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parseHolds(out)
+}
+
+// parseHolds parses the output of `zfs holds -H`.
+func parseHolds(out [][]string) ([]Hold, error) {
+	holds := make([]Hold, 0, len(out))
+	for _, line := range out {
+		if len(line) != 3 {
+			return nil, fmt.Errorf("output does not match what is expected on this platform")
+		}
+		ts, err := time.ParseInLocation(holdTimeLayout, line[2], time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hold timestamp %q: %w", line[2], err)
+		}
+		holds = append(holds, Hold{Name: line[0], Tag: line[1], Timestamp: ts})
+	}
+	return holds, nil
+}
@@ -0,0 +1,42 @@
+package zfs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHolds(t *testing.T) {
+	holds, err := parseHolds([][]string{
+		{"tank/fs@snap", "backup", "Thu Oct  1 09:05 2026"},
+		{"tank/fs@snap", "keep", "Fri Oct 16 12:30 2026"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 2 || holds[0].Tag != "backup" || holds[1].Name != "tank/fs@snap" {
+		t.Fatalf("unexpected holds: %+v", holds)
+	}
+	if want := time.Date(2026, time.October, 1, 9, 5, 0, 0, time.Local); !holds[0].Timestamp.Equal(want) {
+		t.Fatalf("unexpected timestamp: %v", holds[0].Timestamp)
+	}
+
+	if _, err := parseHolds([][]string{{"tank/fs@snap", "backup"}}); err == nil {
+		t.Fatal("wanted an error for a short line")
+	}
+	if _, err := parseHolds([][]string{{"tank/fs@snap", "backup", "yesterday"}}); err == nil {
+		t.Fatal("wanted an error for an invalid timestamp")
+	}
+}
+
+func TestHoldNotSnapshot(t *testing.T) {
+	fs := &Dataset{Name: "tank/fs", Type: DatasetFilesystem}
+	for want, err := range map[string]error{
+		"can only hold snapshots":              fs.Hold("keep", false),
+		"can only release snapshots":           fs.Release("keep", false),
+		"can only list the holds of snapshots": func() error { _, err := fs.Holds(); return err }(),
+	} {
+		if err == nil || err.Error() != want {
+			t.Errorf("wanted %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonProperty is a property as printed by the JSON output of the ZFS tools.
+type jsonProperty struct {
+	Value    string `json:"value"`
+	Received string `json:"received"`
+	Source   struct {
+		Type string `json:"type"`
+		Data string `json:"data"`
+	} `json:"source"`
+}
+
+// jsonObject is a dataset or pool as printed by the JSON output of the ZFS tools. Only the fields needed to rebuild
+// the tab separated output are decoded.
+type jsonObject struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	properties []string
+	values     map[string]jsonProperty
+}
+
+func (o *jsonObject) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Name       string          `json:"name"`
+		Type       string          `json:"type"`
+		Properties json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	o.Name, o.Type = fields.Name, fields.Type
+	o.values = map[string]jsonProperty{}
+	if len(fields.Properties) == 0 {
+		return nil
+	}
+	return decodeOrdered(fields.Properties, func(key string, value json.RawMessage) error {
+		var p jsonProperty
+		if err := json.Unmarshal(value, &p); err != nil {
+			return err
+		}
+		o.properties = append(o.properties, key)
+		o.values[key] = p
+		return nil
+	})
+}
+
+// propertyAliases maps the short property names accepted by the ZFS tools to the full names used in JSON output.
+var propertyAliases = map[string]string{
+	"avail":         "available",
+	"refer":         "referenced",
+	"compress":      "compression",
+	"recsize":       "recordsize",
+	"volblock":      "volblocksize",
+	"reserv":        "reservation",
+	"refreserv":     "refreservation",
+	"lused":         "logicalused",
+	"lrefer":        "logicalreferenced",
+	"usedsnap":      "usedbysnapshots",
+	"usedds":        "usedbydataset",
+	"usedchild":     "usedbychildren",
+	"usedrefreserv": "usedbyrefreservation",
+	"ratio":         "compressratio",
+	"refratio":      "refcompressratio",
+	"cap":           "capacity",
+	"alloc":         "allocated",
+	"frag":          "fragmentation",
+}
+
+// value returns the value of a property, as it would appear in the tab separated output.
+func (o *jsonObject) value(prop string) string {
+	if p, ok := o.values[prop]; ok {
+		return p.Value
+	}
+	if p, ok := o.values[propertyAliases[prop]]; ok {
+		return p.Value
+	}
+	switch prop {
+	case "name":
+		return o.Name
+	case "type":
+		return strings.ToLower(o.Type)
+	}
+	return "-"
+}
+
+// source returns the source of a property, as it would appear in the tab separated output.
+func (o *jsonObject) source(prop string) string {
+	p := o.values[prop]
+	switch p.Source.Type {
+	case "INHERITED":
+		return "inherited from " + p.Source.Data
+	case "NONE", "":
+		return "-"
+	}
+	return strings.ToLower(p.Source.Type)
+}
+
+// decodeOrdered calls fn for each member of the JSON object data, in the order they appear. The ZFS tools print
+// datasets in the same order as the tab separated output, which a map would lose.
+func decodeOrdered(data []byte, fn func(key string, value json.RawMessage) error) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("expected JSON object, got %v", tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("expected JSON object key, got %v", tok)
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// parseJSONObjects decodes the datasets or pools, selected by member, of the JSON output of a ZFS command.
+func parseJSONObjects(data []byte, member string) ([]*jsonObject, error) {
+	var output map[string]json.RawMessage
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON output: %w", err)
+	}
+
+	var objects []*jsonObject
+	if len(output[member]) == 0 {
+		return objects, nil
+	}
+	err := decodeOrdered(output[member], func(key string, value json.RawMessage) error {
+		o := &jsonObject{}
+		if err := json.Unmarshal(value, o); err != nil {
+			return err
+		}
+		if o.Name == "" {
+			o.Name = key
+		}
+		objects = append(objects, o)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON output: %w", err)
+	}
+	return objects, nil
+}
+
+// jsonListLines converts the output of `zfs list -j` or `zpool list -j`, whose objects are found in member, into the
+// lines `zfs list -H -o props` prints.
+func jsonListLines(data []byte, member string, props []string) ([][]string, error) {
+	objects, err := parseJSONObjects(data, member)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([][]string, len(objects))
+	for i, o := range objects {
+		line := make([]string, len(props))
+		for j, prop := range props {
+			line[j] = o.value(prop)
+		}
+		lines[i] = line
+	}
+	return lines, nil
+}
+
+// getFields are the fields printed by `zfs get` and `zpool get` without -o.
+var getFields = []string{"name", "property", "value", "source"}
+
+// jsonGetLines converts the output of `zfs get -j` or `zpool get -j`, whose objects are found in member, into the
+// lines `zfs get -H -o fields` prints.
+func jsonGetLines(data []byte, member string, fields []string) ([][]string, error) {
+	objects, err := parseJSONObjects(data, member)
+	if err != nil {
+		return nil, err
+	}
+	var lines [][]string
+	for _, o := range objects {
+		for _, prop := range o.properties {
+			line := make([]string, len(fields))
+			for i, field := range fields {
+				switch field {
+				case "name":
+					line[i] = o.Name
+				case "property":
+					line[i] = prop
+				case "value":
+					line[i] = o.value(prop)
+				case "received":
+					line[i] = o.values[prop].Received
+					if line[i] == "" {
+						line[i] = "-"
+					}
+				case "source":
+					line[i] = o.source(prop)
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// jsonOutput runs the command at path and returns its standard output.
+func (c *Client) jsonOutput(ctx context.Context, path string, arg ...string) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd := command{Command: path, Stdout: &stdout, client: c}
+	if _, err := cmd.RunContext(ctx, arg...); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// useJSON reports whether JSON output should be requested from the ZFS tools.
+func (c *Client) useJSON(ctx context.Context) bool {
+	return (c == nil || !c.DisableJSON) && c.supports(ctx, FeatureJSON)
+}
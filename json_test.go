@@ -0,0 +1,46 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONGetLines(t *testing.T) {
+	data := []byte(`{
+  "output_version": {"command": "zfs get", "vers_major": 0, "vers_minor": 1},
+  "datasets": {
+    "tank/b": {
+      "name": "tank/b",
+      "type": "FILESYSTEM",
+      "properties": {
+        "compression": {"value": "lz4", "source": {"type": "INHERITED", "data": "tank"}},
+        "used": {"value": "1024", "source": {"type": "NONE", "data": "-"}}
+      }
+    },
+    "tank/a": {
+      "name": "tank/a",
+      "type": "FILESYSTEM",
+      "properties": {
+        "compression": {"value": "off", "source": {"type": "LOCAL", "data": "-"}}
+      }
+    }
+  }
+}`)
+
+	lines, err := jsonGetLines(data, "datasets", getFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"tank/b", "compression", "lz4", "inherited from tank"},
+		{"tank/b", "used", "1024", "-"},
+		{"tank/a", "compression", "off", "local"},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("unexpected lines:\n%q\nwanted:\n%q", lines, want)
+	}
+
+	if _, err := jsonGetLines([]byte("not json"), "datasets", getFields); err == nil {
+		t.Fatal("wanted an error for invalid JSON")
+	}
+}
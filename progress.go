@@ -0,0 +1,125 @@
+package zfs
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Progress is the state of a send or receive stream, reported to ProgressOptions.Func.
+type Progress struct {
+	// Bytes is the number of bytes of the stream transferred so far.
+	Bytes uint64
+	// Total is the expected size of the stream in bytes, or 0 if unknown.
+	Total uint64
+	// Elapsed is the time since the stream started.
+	Elapsed time.Duration
+	// Rate is the average number of bytes transferred per second since the stream started.
+	Rate float64
+	// Remaining is the estimated time until Total bytes are transferred at Rate, or 0 if unknown.
+	Remaining time.Duration
+	// Done is set in the last report, made once the stream ended, successfully or not.
+	Done bool
+}
+
+// ProgressOptions enable progress reporting of the stream of a send or receive, by counting the bytes going
+// through it.
+type ProgressOptions struct {
+	// Func is called with the progress of the stream every Interval, and a last time once the stream ended. Calls
+	// are made from a separate goroutine, one at a time.
+	Func func(Progress)
+	// Interval is the time between reports, one second if zero.
+	Interval time.Duration
+	// Total is the expected size of the stream in bytes, used to estimate the remaining time. When sending, the
+	// estimate of EstimateSend is used if Total is zero.
+	Total uint64
+}
+
+// progress counts the bytes of a stream and reports them.
+type progress struct {
+	bytes uint64 // Accessed atomically, first for alignment on 32-bit platforms.
+
+	opts  *ProgressOptions
+	total uint64
+	start time.Time
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// startProgress starts reporting the progress of a stream of total bytes, until finish is called.
+func startProgress(opts *ProgressOptions, total uint64) *progress {
+	p := &progress{
+		opts:  opts,
+		total: total,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	go func() {
+		defer close(p.done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-p.stop:
+				p.report(true)
+				return
+			case <-t.C:
+				p.report(false)
+			}
+		}
+	}()
+	return p
+}
+
+func (p *progress) report(done bool) {
+	n := atomic.LoadUint64(&p.bytes)
+	r := Progress{Bytes: n, Total: p.total, Elapsed: time.Since(p.start), Done: done}
+	if s := r.Elapsed.Seconds(); s > 0 {
+		r.Rate = float64(n) / s
+	}
+	if r.Rate > 0 && p.total > n {
+		r.Remaining = time.Duration(float64(p.total-n) / r.Rate * float64(time.Second))
+	}
+	p.opts.Func(r)
+}
+
+// finish makes the last report and waits for it to complete.
+func (p *progress) finish() {
+	close(p.stop)
+	<-p.done
+}
+
+func (p *progress) add(n int) {
+	if n > 0 {
+		atomic.AddUint64(&p.bytes, uint64(n))
+	}
+}
+
+// progressWriter counts the bytes written to w.
+type progressWriter struct {
+	w io.Writer
+	p *progress
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.p.add(n)
+	return n, err
+}
+
+// progressReader counts the bytes read from r.
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.p.add(n)
+	return n, err
+}
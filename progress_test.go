@@ -0,0 +1,59 @@
+package zfs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	var mu sync.Mutex
+	var reports []Progress
+	opts := &ProgressOptions{
+		Func: func(p Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, p)
+		},
+		Interval: time.Millisecond,
+	}
+
+	p := startProgress(opts, 100)
+	var buf bytes.Buffer
+	w := &progressWriter{w: &buf, p: p}
+	for i := 0; i < 5; i++ {
+		if _, err := io.WriteString(w, "0123456789"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	p.finish()
+
+	if len(reports) < 2 {
+		t.Fatalf("expected periodic reports, got %+v", reports)
+	}
+	for i, r := range reports[:len(reports)-1] {
+		if r.Done || r.Total != 100 || r.Bytes > 50 || i > 0 && r.Bytes < reports[i-1].Bytes {
+			t.Errorf("unexpected report %d: %+v", i, r)
+		}
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Bytes != 50 || last.Rate <= 0 || last.Remaining <= 0 {
+		t.Errorf("unexpected last report: %+v", last)
+	}
+
+	reports = nil
+	p = startProgress(&ProgressOptions{Func: opts.Func}, 0)
+	n, err := io.Copy(ioutil.Discard, &progressReader{r: strings.NewReader("stream"), p: p})
+	p.finish()
+	if err != nil || n != 6 {
+		t.Fatalf("unexpected copy: %d, %v", n, err)
+	}
+	if len(reports) != 1 || !reports[0].Done || reports[0].Bytes != 6 || reports[0].Remaining != 0 {
+		t.Errorf("unexpected reports: %+v", reports)
+	}
+}
@@ -0,0 +1,206 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sources of a property value, as reported by `zfs get`.
+const (
+	SourceLocal     = "local"
+	SourceDefault   = "default"
+	SourceInherited = "inherited"
+	SourceTemporary = "temporary"
+	SourceReceived  = "received"
+	SourceNone      = "none"
+)
+
+// Property is the value of a ZFS property of a dataset, along with where it comes from.
+type Property struct {
+	Name  string
+	Value string
+	// Received is the value received with `zfs receive`, or "-" if there is none.
+	Received string
+	// Source is one of the Source constants. SourceNone is used for read-only properties.
+	Source string
+	// InheritedFrom is the name of the dataset the value is inherited from, when Source is SourceInherited.
+	InheritedFrom string
+}
+
+// propertyFields are the fields of `zfs get` parsed by parseProperty.
+var propertyFields = []string{"name", "property", "value", "received", "source"}
+
+// parseProperty parses a line of `zfs get -H -o name,property,value,received,source`.
+func parseProperty(line []string) (Property, error) {
+	if len(line) != len(propertyFields) {
+		return Property{}, fmt.Errorf("output does not match what is expected on this platform")
+	}
+	p := Property{Name: line[1], Value: line[2], Received: line[3], Source: line[4]}
+	switch {
+	case p.Source == "-":
+		p.Source = SourceNone
+	case strings.HasPrefix(p.Source, "inherited from "):
+		p.InheritedFrom = strings.TrimPrefix(p.Source, "inherited from ")
+		p.Source = SourceInherited
+	}
+	return p, nil
+}
+
+// GetProperties returns the named ZFS properties of the receiving dataset, keyed by property name.
+//
+// A full list of available ZFS properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
+func (d *Dataset) GetProperties(names ...string) (map[string]Property, error) {
+	return d.GetPropertiesContext(context.Background(), names...)
+}
+
+// GetPropertiesContext is like GetProperties but includes a context.
+func (d *Dataset) GetPropertiesContext(ctx context.Context, names ...string) (map[string]Property, error) {
+	if len(names) == 0 {
+		return map[string]Property{}, nil
+	}
+	return d.getProperties(ctx, strings.Join(names, ","))
+}
+
+// GetAllProperties returns all ZFS properties of the receiving dataset, including user properties, keyed by property
+// name.
+func (d *Dataset) GetAllProperties() (map[string]Property, error) {
+	return d.GetAllPropertiesContext(context.Background())
+}
+
+// GetAllPropertiesContext is like GetAllProperties but includes a context.
+func (d *Dataset) GetAllPropertiesContext(ctx context.Context) (map[string]Property, error) {
+	return d.getProperties(ctx, "all")
+}
+
+func (d *Dataset) getProperties(ctx context.Context, names string) (map[string]Property, error) {
+	out, err := d.client.getOutput(ctx, d.client.zfsPath(), propertyFields, names, d.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	datasets, err := parseProperties(out)
+	if err != nil {
+		return nil, err
+	}
+	props := datasets[d.Name]
+	if props == nil {
+		props = map[string]Property{}
+	}
+	return props, nil
+}
+
+// parseProperties parses the output of `zfs get -H -o name,property,value,received,source`, keyed by dataset name
+// then property name.
+func parseProperties(out [][]string) (map[string]map[string]Property, error) {
+	datasets := map[string]map[string]Property{}
+	for _, line := range out {
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		props, ok := datasets[line[0]]
+		if !ok {
+			props = map[string]Property{}
+			datasets[line[0]] = props
+		}
+		props[p.Name] = p
+	}
+	return datasets, nil
+}
+
+// ListProperties returns the named properties of the dataset name and its descendants, keyed by dataset name then
+// property name, using a single zfs command.
+// A recursion depth may be specified, or a depth of 0 allows unlimited recursion. If name is empty, all datasets are
+// listed. typ restricts the listed datasets to a comma separated list of types, such as DatasetSnapshot, or all types
+// if empty. If no property names are given, all properties are returned.
+func ListProperties(name string, depth uint64, typ string, names ...string) (map[string]map[string]Property, error) {
+	return defaultClient.ListProperties(name, depth, typ, names...)
+}
+
+// ListPropertiesContext is like ListProperties but includes a context.
+func ListPropertiesContext(ctx context.Context, name string, depth uint64, typ string, names ...string) (map[string]map[string]Property, error) {
+	return defaultClient.ListPropertiesContext(ctx, name, depth, typ, names...)
+}
+
+// ListProperties returns the named properties of the dataset name and its descendants, keyed by dataset name then
+// property name, using a single zfs command.
+// A recursion depth may be specified, or a depth of 0 allows unlimited recursion. If name is empty, all datasets are
+// listed. typ restricts the listed datasets to a comma separated list of types, such as DatasetSnapshot, or all types
+// if empty. If no property names are given, all properties are returned.
+func (c *Client) ListProperties(name string, depth uint64, typ string, names ...string) (map[string]map[string]Property, error) {
+	return c.ListPropertiesContext(context.Background(), name, depth, typ, names...)
+}
+
+// ListPropertiesContext is like ListProperties but includes a context.
+func (c *Client) ListPropertiesContext(ctx context.Context, name string, depth uint64, typ string, names ...string) (map[string]map[string]Property, error) {
+	var args []string
+	if depth > 0 {
+		args = append(args, "-d", strconv.FormatUint(depth, 10))
+	} else {
+		args = append(args, "-r")
+	}
+	if typ != "" {
+		args = append(args, "-t", typ)
+	}
+	if len(names) == 0 {
+		args = append(args, "all")
+	} else {
+		args = append(args, strings.Join(names, ","))
+	}
+	if name != "" {
+		args = append(args, name)
+	}
+
+	out, err := c.getOutput(ctx, c.zfsPath(), propertyFields, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseProperties(out)
+}
+
+// SetProperties sets several ZFS properties on the receiving dataset at once.
+//
+// A full list of available ZFS properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
+func (d *Dataset) SetProperties(properties map[string]string) error {
+	return d.SetPropertiesContext(context.Background(), properties)
+}
+
+// SetPropertiesContext is like SetProperties but includes a context.
+func (d *Dataset) SetPropertiesContext(ctx context.Context, properties map[string]string) error {
+	if len(properties) == 0 {
+		return nil
+	}
+	args := make([]string, 1, len(properties)+2)
+	args[0] = "set"
+	for k, v := range properties {
+		args = append(args, k+"="+v)
+	}
+	sort.Strings(args[1:])
+	args = append(args, d.Name)
+	return d.client.zfs(ctx, args...)
+}
+
+// InheritProperty clears a ZFS property of the receiving dataset, so that it is inherited from its parent or takes
+// its default value. If recursive is true, the property is also cleared on all descendants. If received is true, the
+// property reverts to the value received with `zfs receive`, if any.
+func (d *Dataset) InheritProperty(name string, recursive, received bool) error {
+	return d.InheritPropertyContext(context.Background(), name, recursive, received)
+}
+
+// InheritPropertyContext is like InheritProperty but includes a context.
+func (d *Dataset) InheritPropertyContext(ctx context.Context, name string, recursive, received bool) error {
+	args := []string{"inherit"}
+	if recursive {
+		args = append(args, "-r")
+	}
+	if received {
+		args = append(args, "-S")
+	}
+	args = append(args, name, d.Name)
+	return d.client.zfs(ctx, args...)
+}
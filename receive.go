@@ -0,0 +1,165 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ReceiveOptions are the options of Receive, each but Progress corresponding to a flag of `zfs receive`.
+type ReceiveOptions struct {
+	// Force rolls the destination back to its most recent snapshot before receiving an incremental stream, and
+	// destroys the snapshots and datasets missing from a replication stream (-F).
+	Force bool
+	// NoMount leaves the received filesystems unmounted (-u).
+	NoMount bool
+	// Resumable saves the state of an interrupted receive, so that it can be resumed with the token found in the
+	// receive_resume_token property of the destination (-s).
+	Resumable bool
+
+	// Props are set on the destination as if by `zfs set` right before the receive, overriding the values found
+	// in the stream (-o).
+	Props map[string]string
+	// Exclude lists properties whose values found in the stream are ignored, so that they are inherited (-x).
+	Exclude []string
+
+	// DiscardFirst receives the stream below the destination, under the name of the sent dataset without its first
+	// element, usually the pool name (-d). Missing intermediate filesystems are created.
+	DiscardFirst bool
+	// LastElement receives the stream below the destination, under the last element of the name of the sent
+	// dataset (-e).
+	LastElement bool
+
+	// DryRun checks the stream and reports the snapshots it would create, without receiving anything (-n).
+	DryRun bool
+
+	// Progress, if not nil, enables progress reporting of the stream.
+	Progress *ProgressOptions
+}
+
+// args returns the flags of `zfs receive` for o.
+func (o *ReceiveOptions) args() []string {
+	var args []string
+	for _, f := range []struct {
+		set  bool
+		flag string
+	}{
+		{o.Force, "-F"},
+		{o.NoMount, "-u"},
+		{o.Resumable, "-s"},
+		{o.DiscardFirst, "-d"},
+		{o.LastElement, "-e"},
+		{o.DryRun, "-n"},
+	} {
+		if f.set {
+			args = append(args, f.flag)
+		}
+	}
+	names := make([]string, 0, len(o.Props))
+	for name := range o.Props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-o", name+"="+o.Props[name])
+	}
+	for _, name := range o.Exclude {
+		args = append(args, "-x", name)
+	}
+	return args
+}
+
+// ReceivedStream is a stream of a single snapshot, part of the input of Receive.
+type ReceivedStream struct {
+	// Source is the name of the sent snapshot.
+	Source string
+	// Target is the name of the received snapshot.
+	Target string
+	// Incremental is true if the stream builds on an earlier snapshot of the target.
+	Incremental bool
+}
+
+// ReceiveResult is the outcome of Receive.
+type ReceiveResult struct {
+	// Dataset is the received snapshot when a snapshot name was given to Receive, and otherwise the filesystem or
+	// volume the stream was received into, which DiscardFirst and LastElement place below the given name. It is nil
+	// for dry runs.
+	Dataset *Dataset
+	// Streams are the streams received, or which a dry run would receive, in order.
+	Streams []ReceivedStream
+}
+
+// receivedRegex matches the lines printed by `zfs receive -v` for each stream.
+var receivedRegex = regexp.MustCompile(`^(?:receiving|would receive) (full|incremental) stream of (\S+) into (\S+)$`)
+
+// parseReceived parses the output of `zfs receive -v`.
+func parseReceived(out [][]string) []ReceivedStream {
+	var streams []ReceivedStream
+	for _, line := range out {
+		m := receivedRegex.FindStringSubmatch(strings.Join(line, "\t"))
+		if m == nil {
+			continue
+		}
+		streams = append(streams, ReceivedStream{Source: m[2], Target: m[3], Incremental: m[1] == "incremental"})
+	}
+	return streams
+}
+
+// Receive receives a ZFS stream from the input io.Reader into name, as specified by opts, and reports the received
+// snapshots. name may be a snapshot, or the filesystem or volume receiving the snapshots of the stream.
+func Receive(input io.Reader, name string, opts ReceiveOptions) (*ReceiveResult, error) {
+	return defaultClient.Receive(input, name, opts)
+}
+
+// ReceiveContext is like Receive but includes a context.
+func ReceiveContext(ctx context.Context, input io.Reader, name string, opts ReceiveOptions) (*ReceiveResult, error) {
+	return defaultClient.ReceiveContext(ctx, input, name, opts)
+}
+
+// Receive receives a ZFS stream from the input io.Reader into name, as specified by opts, and reports the received
+// snapshots. name may be a snapshot, or the filesystem or volume receiving the snapshots of the stream.
+func (c *Client) Receive(input io.Reader, name string, opts ReceiveOptions) (*ReceiveResult, error) {
+	return c.ReceiveContext(context.Background(), input, name, opts)
+}
+
+// ReceiveContext is like Receive but includes a context.
+func (c *Client) ReceiveContext(ctx context.Context, input io.Reader, name string, opts ReceiveOptions) (*ReceiveResult, error) {
+	if opts.DiscardFirst && opts.LastElement {
+		return nil, errors.New("DiscardFirst and LastElement cannot be used together")
+	}
+	if (opts.DiscardFirst || opts.LastElement) && strings.Contains(name, "@") {
+		return nil, errors.New("DiscardFirst and LastElement require a filesystem as the destination")
+	}
+
+	if opts.Progress != nil && opts.Progress.Func != nil {
+		p := startProgress(opts.Progress, opts.Progress.Total)
+		defer p.finish()
+		input = &progressReader{r: input, p: p}
+	}
+
+	args := append([]string{"receive", "-v"}, opts.args()...)
+	cmd := command{Command: c.zfsPath(), Stdin: input, client: c}
+	out, err := cmd.RunContext(ctx, append(args, name)...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ReceiveResult{Streams: parseReceived(out)}
+	if opts.DryRun {
+		return res, nil
+	}
+	if !strings.Contains(name, "@") && len(res.Streams) > 0 {
+		name = res.Streams[0].Target
+		if i := strings.IndexByte(name, '@'); i >= 0 {
+			name = name[:i]
+		}
+	}
+	res.Dataset, err = c.GetDatasetContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,37 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReceiveOptionsArgs(t *testing.T) {
+	opts := ReceiveOptions{
+		Force:        true,
+		NoMount:      true,
+		Resumable:    true,
+		Props:        map[string]string{"readonly": "on", "compression": "lz4"},
+		Exclude:      []string{"mountpoint"},
+		DiscardFirst: true,
+		DryRun:       true,
+	}
+	want := []string{"-F", "-u", "-s", "-d", "-n", "-o", "compression=lz4", "-o", "readonly=on", "-x", "mountpoint"}
+	if got := opts.args(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, wanted %q", got, want)
+	}
+}
+
+func TestParseReceived(t *testing.T) {
+	streams := parseReceived([][]string{
+		{"receiving full stream of tank/src@a into backup/src@a"},
+		{"received 312B stream in 1 seconds (312B/sec)"},
+		{"would receive incremental stream of tank/src@b into backup/src@b"},
+	})
+	want := []ReceivedStream{
+		{Source: "tank/src@a", Target: "backup/src@a"},
+		{Source: "tank/src@b", Target: "backup/src@b", Incremental: true},
+	}
+	if !reflect.DeepEqual(streams, want) {
+		t.Fatalf("got %+v, wanted %+v", streams, want)
+	}
+}
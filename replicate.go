@@ -0,0 +1,57 @@
+package zfs
+
+import (
+	"context"
+	"io"
+)
+
+// ReplicateOptions are the options of Replicate.
+type ReplicateOptions struct {
+	Send    SendOptions
+	Receive ReceiveOptions
+}
+
+// Replicate sends the snapshot src into the dataset dst by piping `zfs send` into `zfs receive`, as specified by
+// opts. If either side fails, the other is stopped: the send is killed when the receive fails, and the receive gets
+// an incomplete stream when the send fails. The error is then a *ReplicationError holding the errors and standard
+// error outputs of both sides.
+func Replicate(src *Dataset, dst string, opts ReplicateOptions) (*ReceiveResult, error) {
+	return defaultClient.Replicate(src, dst, opts)
+}
+
+// ReplicateContext is like Replicate but includes a context.
+func ReplicateContext(ctx context.Context, src *Dataset, dst string, opts ReplicateOptions) (*ReceiveResult, error) {
+	return defaultClient.ReplicateContext(ctx, src, dst, opts)
+}
+
+// Replicate sends the snapshot src into the dataset dst by piping `zfs send` into `zfs receive`, as specified by
+// opts. The stream is sent with the client of src and received with c, which may differ to replicate between hosts.
+// If either side fails, the other is stopped: the send is killed when the receive fails, and the receive gets an
+// incomplete stream when the send fails. The error is then a *ReplicationError holding the errors and standard
+// error outputs of both sides.
+//
+// When both clients run commands as local processes, the standard output of `zfs send` is connected to the standard
+// input of `zfs receive` by an OS pipe. Otherwise, as with a RemoteExecutor, the stream is copied through the
+// current process.
+func (c *Client) Replicate(src *Dataset, dst string, opts ReplicateOptions) (*ReceiveResult, error) {
+	return c.ReplicateContext(context.Background(), src, dst, opts)
+}
+
+// ReplicateContext is like Replicate but includes a context.
+func (c *Client) ReplicateContext(ctx context.Context, src *Dataset, dst string, opts ReplicateOptions) (*ReceiveResult, error) {
+	if err := src.client.checkSend(ctx, src, &opts.Send); err != nil {
+		return nil, err
+	}
+	var res *ReceiveResult
+	err := transfer(ctx, src.client.local() && c.local(), func(ctx context.Context, w io.Writer) error {
+		return src.SendContext(ctx, w, opts.Send)
+	}, func(ctx context.Context, r io.Reader) error {
+		var err error
+		res, err = c.ReceiveContext(ctx, r, dst, opts.Receive)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
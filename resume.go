@@ -0,0 +1,199 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResumeToken returns the receive_resume_token property of the receiving dataset, which is set when a Receive with
+// ReceiveOptions.Resumable was interrupted, or empty string ("") if there is no partially received state.
+func (d *Dataset) ResumeToken() (string, error) {
+	return d.ResumeTokenContext(context.Background())
+}
+
+// ResumeTokenContext is like ResumeToken but includes a context.
+func (d *Dataset) ResumeTokenContext(ctx context.Context) (string, error) {
+	token, err := d.GetPropertyContext(ctx, "receive_resume_token")
+	if err != nil || token == "-" {
+		return "", err
+	}
+	return token, nil
+}
+
+// AbortReceive discards the partially received state of the receiving dataset, saved by an interrupted Receive
+// with ReceiveOptions.Resumable. A dataset created by the interrupted receive is destroyed.
+func (d *Dataset) AbortReceive() error {
+	return d.AbortReceiveContext(context.Background())
+}
+
+// AbortReceiveContext is like AbortReceive but includes a context.
+func (d *Dataset) AbortReceiveContext(ctx context.Context) error {
+	return d.client.zfs(ctx, "receive", "-A", d.Name)
+}
+
+// ResumeSend sends the remainder of an interrupted stream to the input io.Writer, from the token found on the
+// receiving side with Dataset.ResumeToken. The stream is to be received with ReceiveOptions.Resumable into the
+// dataset the token was read from.
+func ResumeSend(token string, output io.Writer) error {
+	return defaultClient.ResumeSend(token, output)
+}
+
+// ResumeSendContext is like ResumeSend but includes a context.
+func ResumeSendContext(ctx context.Context, token string, output io.Writer) error {
+	return defaultClient.ResumeSendContext(ctx, token, output)
+}
+
+// ResumeSend sends the remainder of an interrupted stream to the input io.Writer, from the token found on the
+// receiving side with Dataset.ResumeToken. The stream is to be received with ReceiveOptions.Resumable into the
+// dataset the token was read from.
+func (c *Client) ResumeSend(token string, output io.Writer) error {
+	return c.ResumeSendContext(context.Background(), token, output)
+}
+
+// ResumeSendContext is like ResumeSend but includes a context.
+func (c *Client) ResumeSendContext(ctx context.Context, token string, output io.Writer) error {
+	if token == "" {
+		return errors.New("empty resume token")
+	}
+	cmd := command{Command: c.zfsPath(), Stdout: output, client: c}
+	_, err := cmd.RunContext(ctx, "send", "-t", token)
+	return err
+}
+
+// resumeTokenTarget returns the name and guid of the snapshot whose send the resume token continues, decoded with
+// `zfs send -nvt`.
+func (c *Client) resumeTokenTarget(ctx context.Context, token string) (string, uint64, error) {
+	out, err := c.zfsOutput(ctx, "send", "-nvt", token)
+	if err != nil {
+		return "", 0, err
+	}
+	var name string
+	var guid uint64
+	for _, line := range out {
+		field := strings.SplitN(strings.TrimSpace(strings.Join(line, "\t")), " = ", 2)
+		if len(field) != 2 {
+			continue
+		}
+		switch field[0] {
+		case "toname":
+			name = field[1]
+		case "toguid":
+			if guid, err = strconv.ParseUint(field[1], 0, 64); err != nil {
+				return "", 0, fmt.Errorf("invalid toguid in resume token: %q", field[1])
+			}
+		}
+	}
+	if name == "" {
+		return "", 0, errors.New("resume token has no toname")
+	}
+	return name, guid, nil
+}
+
+// checkResumeToken returns an error if the resume token does not continue the send of the receiving snapshot.
+func (d *Dataset) checkResumeToken(ctx context.Context, token string) error {
+	name, guid, err := d.client.resumeTokenTarget(ctx, token)
+	if err != nil {
+		return err
+	}
+	if guid != 0 {
+		value, err := d.GetPropertyContext(ctx, "guid")
+		if err != nil {
+			return err
+		}
+		if strconv.FormatUint(guid, 10) == value {
+			return nil
+		}
+	} else if name == d.Name {
+		return nil
+	}
+	return fmt.Errorf("partially received state is from %s, not %s: discard it with AbortReceive", name, d.Name)
+}
+
+// ResumableOptions are the options of Dataset.SendResumable.
+type ResumableOptions struct {
+	Send    SendOptions
+	Receive ReceiveOptions
+
+	// Retries is the number of times an interrupted transfer is resumed before giving up.
+	Retries int
+	// Delay is the time waited before resuming an interrupted transfer.
+	Delay time.Duration
+}
+
+// SendResumable sends the receiving snapshot into the dataset name, managed by the client dst, by piping Send into
+// Receive with ReceiveOptions.Resumable set. When the transfer is interrupted after part of the stream was received,
+// it is resumed from the token saved on name, up to opts.Retries times. If name already holds partially received
+// state, for instance from an earlier call, the transfer resumes from it right away. That state must be from a send
+// of the receiving snapshot, which is checked by decoding its token with `zfs send -nvt`: the state of any other
+// send is left in place and an error returned.
+//
+// The partially received state is left in place when all attempts fail, so that the transfer can be resumed later,
+// or discarded with AbortReceive. Replication streams cannot be resumed, and the name of the received dataset must
+// be given rather than derived with ReceiveOptions.DiscardFirst or LastElement.
+func (d *Dataset) SendResumable(dst *Client, name string, opts ResumableOptions) (*ReceiveResult, error) {
+	return d.SendResumableContext(context.Background(), dst, name, opts)
+}
+
+// SendResumableContext is like SendResumable but includes a context.
+func (d *Dataset) SendResumableContext(ctx context.Context, dst *Client, name string, opts ResumableOptions) (*ReceiveResult, error) {
+	switch {
+	case opts.Send.Replicate:
+		return nil, errors.New("replication streams cannot be resumed")
+	case opts.Receive.DiscardFirst || opts.Receive.LastElement:
+		return nil, errors.New("resumable transfers require the name of the received dataset")
+	}
+	if err := d.client.checkSend(ctx, d, &opts.Send); err != nil {
+		return nil, err
+	}
+	recvOpts := opts.Receive
+	recvOpts.Resumable = true
+	partial := &Dataset{Name: name, client: dst}
+	if i := strings.IndexByte(name, '@'); i >= 0 {
+		partial.Name = name[:i]
+	}
+
+	token, err := partial.ResumeTokenContext(ctx)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if token != "" {
+		if err := d.checkResumeToken(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		var res *ReceiveResult
+		err := transfer(ctx, d.client.local() && dst.local(), func(ctx context.Context, w io.Writer) error {
+			if token != "" {
+				return d.client.ResumeSendContext(ctx, token, w)
+			}
+			return d.SendContext(ctx, w, opts.Send)
+		}, func(ctx context.Context, r io.Reader) error {
+			var err error
+			res, err = dst.ReceiveContext(ctx, r, name, recvOpts)
+			return err
+		})
+		if err == nil {
+			return res, nil
+		}
+		if attempt >= opts.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+		if token, _ = partial.ResumeTokenContext(ctx); token == "" {
+			return nil, err
+		}
+
+		t := time.NewTimer(opts.Delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package retention decides which snapshots to keep under a grandfather-father-son policy, and destroys the others.
+//
+// Snapshots must be listed with the extra Properties, so that their creation time and holds are known:
+//
+//	snapshots, err := dataset.Snapshots(retention.Properties...)
+//	plan, err := retention.Policy{Daily: 7, Weekly: 4, Monthly: 12, Prefix: "auto-"}.Plan(snapshots)
+//	plan.Report(os.Stdout)
+//	err = plan.Apply(nil)
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+)
+
+// Properties are the extra properties snapshots must be listed with, using zfs.Snapshots or Dataset.Snapshots, for
+// Policy.Plan.
+var Properties = []string{"creation", "userrefs"}
+
+// Reasons a snapshot is kept, as found in Decision.Reasons.
+const (
+	ReasonLatest  = "latest"
+	ReasonHourly  = "hourly"
+	ReasonDaily   = "daily"
+	ReasonWeekly  = "weekly"
+	ReasonMonthly = "monthly"
+	ReasonYearly  = "yearly"
+	ReasonPrefix  = "prefix"
+	ReasonHeld    = "held"
+)
+
+// Policy is a set of rules deciding which snapshots of a dataset to keep. A snapshot is kept if any rule keeps it,
+// and destroyed otherwise. Snapshots with user holds are always kept, as they cannot be destroyed.
+//
+// The periodic rules keep the newest snapshot of each of the most recent periods which have snapshots, so that
+// missing snapshots, such as while a machine was off, do not shorten the history.
+type Policy struct {
+	// Latest is the number of most recent snapshots kept regardless of their age.
+	Latest int
+	// Hourly, Daily, Weekly, Monthly and Yearly are the numbers of hours, days, ISO weeks, months and years for
+	// which the newest snapshot is kept.
+	Hourly  int
+	Daily   int
+	Weekly  int
+	Monthly int
+	Yearly  int
+
+	// Prefix, if not empty, restricts the policy to the snapshots whose name, after the @, starts with it, such as
+	// those taken by a scheduler. Other snapshots are left out of the plan.
+	Prefix string
+	// Keep lists prefixes of snapshot names, after the @, which are always kept.
+	Keep []string
+
+	// Location is the time zone periods are computed in, time.Local if nil.
+	Location *time.Location
+}
+
+// Decision is the fate of a snapshot under a Policy.
+type Decision struct {
+	Snapshot *zfs.Dataset
+	// Created is the creation time of the snapshot.
+	Created time.Time
+	// Reasons are the rules keeping the snapshot, such as ReasonDaily, or empty if it is to be destroyed.
+	Reasons []string
+}
+
+// Keep reports whether the snapshot is kept.
+func (d *Decision) Keep() bool {
+	return len(d.Reasons) > 0
+}
+
+// Plan is the outcome of a Policy applied to snapshots.
+type Plan struct {
+	// Decisions cover the snapshots the policy applies to, grouped by dataset in the order datasets were first found,
+	// newest first.
+	Decisions []Decision
+}
+
+// periods are the periodic rules, each with the key of the period a time falls in.
+var periods = []struct {
+	reason string
+	count  func(*Policy) int
+	key    func(time.Time) string
+}{
+	{ReasonHourly, func(p *Policy) int { return p.Hourly }, func(t time.Time) string { return t.Format("2006-01-02T15") }},
+	{ReasonDaily, func(p *Policy) int { return p.Daily }, func(t time.Time) string { return t.Format("2006-01-02") }},
+	{ReasonWeekly, func(p *Policy) int { return p.Weekly }, func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	}},
+	{ReasonMonthly, func(p *Policy) int { return p.Monthly }, func(t time.Time) string { return t.Format("2006-01") }},
+	{ReasonYearly, func(p *Policy) int { return p.Yearly }, func(t time.Time) string { return t.Format("2006") }},
+}
+
+// Plan decides which of snapshots to keep, the snapshots of each dataset being considered separately. Snapshots
+// must have been listed with Properties.
+func (p Policy) Plan(snapshots []*zfs.Dataset) (*Plan, error) {
+	loc := p.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	var datasets []string
+	byDataset := map[string][]Decision{}
+	for _, s := range snapshots {
+		i := strings.IndexByte(s.Name, '@')
+		if s.Type != zfs.DatasetSnapshot || i < 0 {
+			return nil, fmt.Errorf("not a snapshot: %s", s.Name)
+		}
+		if !strings.HasPrefix(s.Name[i+1:], p.Prefix) {
+			continue
+		}
+		creation, err := strconv.ParseInt(s.Extra["creation"], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid creation of %s: %q", s.Name, s.Extra["creation"])
+		}
+
+		d := Decision{Snapshot: s, Created: time.Unix(creation, 0).In(loc)}
+		if refs, err := strconv.ParseUint(s.Extra["userrefs"], 10, 64); err == nil && refs > 0 {
+			d.Reasons = append(d.Reasons, ReasonHeld)
+		}
+		for _, prefix := range p.Keep {
+			if strings.HasPrefix(s.Name[i+1:], prefix) {
+				d.Reasons = append(d.Reasons, ReasonPrefix)
+				break
+			}
+		}
+
+		name := s.Name[:i]
+		if _, ok := byDataset[name]; !ok {
+			datasets = append(datasets, name)
+		}
+		byDataset[name] = append(byDataset[name], d)
+	}
+
+	plan := &Plan{}
+	for _, name := range datasets {
+		decisions := byDataset[name]
+		// Snapshots are listed oldest first: reverse them so that the newest of those created in the same second,
+		// the resolution of the creation property, comes first.
+		for i, j := 0, len(decisions)-1; i < j; i, j = i+1, j-1 {
+			decisions[i], decisions[j] = decisions[j], decisions[i]
+		}
+		sort.SliceStable(decisions, func(i, j int) bool { return decisions[i].Created.After(decisions[j].Created) })
+
+		for i := 0; i < p.Latest && i < len(decisions); i++ {
+			decisions[i].Reasons = append(decisions[i].Reasons, ReasonLatest)
+		}
+		for _, period := range periods {
+			n := period.count(&p)
+			last := ""
+			for i := range decisions {
+				if n <= 0 {
+					break
+				}
+				if key := period.key(decisions[i].Created); key != last {
+					decisions[i].Reasons = append(decisions[i].Reasons, period.reason)
+					last = key
+					n--
+				}
+			}
+		}
+		plan.Decisions = append(plan.Decisions, decisions...)
+	}
+	return plan, nil
+}
+
+// Destroy returns the snapshots to destroy.
+func (p *Plan) Destroy() []*zfs.Dataset {
+	var snapshots []*zfs.Dataset
+	for i := range p.Decisions {
+		if !p.Decisions[i].Keep() {
+			snapshots = append(snapshots, p.Decisions[i].Snapshot)
+		}
+	}
+	return snapshots
+}
+
+// Report writes the plan to w for a dry run, one snapshot per line: "keep", the snapshot name and the reasons it is
+// kept, or "destroy" and the snapshot name, separated by tabs.
+func (p *Plan) Report(w io.Writer) error {
+	for i := range p.Decisions {
+		d := &p.Decisions[i]
+		var err error
+		if d.Keep() {
+			_, err = fmt.Fprintf(w, "keep\t%s\t%s\n", d.Snapshot.Name, strings.Join(d.Reasons, ","))
+		} else {
+			_, err = fmt.Fprintf(w, "destroy\t%s\n", d.Snapshot.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply destroys the snapshots of the plan which are not kept with zfs.DestroySnapshots, one dataset after the other,
+// using the client c, or the default client if nil. DestroySnapshots splits the names of each dataset into batches
+// below the length limit of command line arguments. Apply stops at the first batch which fails: the snapshots of
+// the datasets and batches before it are destroyed, those of the failed batch and after it may be left.
+func (p *Plan) Apply(c *zfs.Client) error {
+	return p.ApplyContext(context.Background(), c)
+}
+
+// ApplyContext is like Apply but includes a context.
+func (p *Plan) ApplyContext(ctx context.Context, c *zfs.Client) error {
+	var datasets []string
+	names := map[string][]string{}
+	for _, s := range p.Destroy() {
+		i := strings.IndexByte(s.Name, '@')
+		if i < 0 {
+			return errors.New("not a snapshot: " + s.Name)
+		}
+		dataset := s.Name[:i]
+		if _, ok := names[dataset]; !ok {
+			datasets = append(datasets, dataset)
+		}
+		names[dataset] = append(names[dataset], s.Name[i+1:])
+	}
+
+	for _, dataset := range datasets {
+		if err := c.DestroySnapshotsContext(ctx, dataset, names[dataset], zfs.DestroyDefault); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package retention_test
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+	"github.com/mistifyio/go-zfs/v4/retention"
+	"github.com/mistifyio/go-zfs/v4/zfstest"
+)
+
+func ok(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func snapshot(name string, created time.Time, userrefs int) *zfs.Dataset {
+	return &zfs.Dataset{
+		Name: name,
+		Type: zfs.DatasetSnapshot,
+		Extra: map[string]string{
+			"creation": strconv.FormatInt(created.Unix(), 10),
+			"userrefs": strconv.Itoa(userrefs),
+		},
+	}
+}
+
+func kept(plan *retention.Plan) map[string]string {
+	out := map[string]string{}
+	for _, d := range plan.Decisions {
+		out[d.Snapshot.Name] = strings.Join(d.Reasons, ",")
+	}
+	return out
+}
+
+func TestPlan(t *testing.T) {
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var snapshots []*zfs.Dataset
+	// Two snapshots a day for 40 days, at 00:00 and 12:00.
+	for i := 0; i < 80; i++ {
+		created := start.Add(time.Duration(i) * 12 * time.Hour)
+		snapshots = append(snapshots, snapshot("tank/fs@auto-"+created.Format("0102-15"), created, 0))
+	}
+	snapshots = append(snapshots,
+		snapshot("tank/fs@manual", start, 0),
+		snapshot("tank/fs@auto-held", start.Add(time.Hour), 1),
+		snapshot("tank/other@auto-0101-00", start, 0),
+	)
+
+	plan, err := retention.Policy{
+		Latest:   1,
+		Hourly:   2,
+		Daily:    3,
+		Weekly:   2,
+		Monthly:  2,
+		Prefix:   "auto-",
+		Keep:     []string{"auto-0105"},
+		Location: time.UTC,
+	}.Plan(snapshots)
+	ok(t, err)
+
+	want := map[string]string{
+		"tank/fs@auto-0209-12":    "latest,hourly,daily,weekly,monthly",
+		"tank/fs@auto-0209-00":    "hourly",
+		"tank/fs@auto-0208-12":    "daily,weekly",
+		"tank/fs@auto-0207-12":    "daily",
+		"tank/fs@auto-0131-12":    "monthly",
+		"tank/fs@auto-0105-00":    "prefix",
+		"tank/fs@auto-0105-12":    "prefix",
+		"tank/fs@auto-held":       "held",
+		"tank/other@auto-0101-00": "latest,hourly,daily,weekly,monthly",
+	}
+	got := kept(plan)
+	if len(got) != 82 {
+		t.Fatalf("wanted 82 decisions, got %d", len(got))
+	}
+	for name, reasons := range got {
+		if reasons != want[name] {
+			t.Errorf("%s: wanted reasons %q, got %q", name, want[name], reasons)
+		}
+	}
+	if n := len(plan.Destroy()); n != 82-len(want) {
+		t.Errorf("wanted %d snapshots to destroy, got %d", 82-len(want), n)
+	}
+	if first := plan.Decisions[0].Snapshot.Name; first != "tank/fs@auto-0209-12" {
+		t.Errorf("wanted the newest snapshot first, got %s", first)
+	}
+
+	if _, err := (retention.Policy{}).Plan([]*zfs.Dataset{{Name: "tank/fs", Type: zfs.DatasetFilesystem}}); err == nil {
+		t.Error("wanted an error for a filesystem")
+	}
+	if _, err := (retention.Policy{}).Plan([]*zfs.Dataset{{Name: "tank/fs@a", Type: zfs.DatasetSnapshot}}); err == nil {
+		t.Error("wanted an error for a snapshot without creation")
+	}
+}
+
+func TestApply(t *testing.T) {
+	emu := zfstest.NewEmulator()
+	c := &zfs.Client{Runner: &zfs.Runner{Executor: emu}}
+	_, err := c.CreateZpool("tank", nil, "/dev/null")
+	ok(t, err)
+	fs, err := c.CreateFilesystem("tank/fs", nil)
+	ok(t, err)
+
+	now := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	emu.Now = func() time.Time { return now }
+	for _, name := range []string{"a", "b", "c", "d"} {
+		_, err = fs.Snapshot(name, false)
+		ok(t, err)
+		now = now.Add(24 * time.Hour)
+	}
+	held, err := c.GetDataset("tank/fs@a")
+	ok(t, err)
+	ok(t, held.Hold("keep", false))
+
+	snapshots, err := c.Snapshots("tank/fs", retention.Properties...)
+	ok(t, err)
+	plan, err := retention.Policy{Daily: 2, Location: time.UTC}.Plan(snapshots)
+	ok(t, err)
+
+	var report bytes.Buffer
+	ok(t, plan.Report(&report))
+	want := "keep\ttank/fs@d\tdaily\nkeep\ttank/fs@c\tdaily\ndestroy\ttank/fs@b\nkeep\ttank/fs@a\theld\n"
+	if report.String() != want {
+		t.Fatalf("unexpected report:\n%s", report.String())
+	}
+
+	ok(t, plan.Apply(c))
+	snapshots, err = c.Snapshots("tank/fs")
+	ok(t, err)
+	var names []string
+	for _, s := range snapshots {
+		names = append(names, s.Name)
+	}
+	if want := []string{"tank/fs@a", "tank/fs@c", "tank/fs@d"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("wanted %v left, got %v", want, names)
+	}
+}
+
+func TestPlanDatasetSnapshots(t *testing.T) {
+	emu := zfstest.NewEmulator()
+	c := &zfs.Client{Runner: &zfs.Runner{Executor: emu}}
+	pool, err := c.CreateZpool("tank", nil, "/dev/null")
+	ok(t, err)
+	fs, err := c.CreateFilesystem("tank/fs", nil)
+	ok(t, err)
+
+	now := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	emu.Now = func() time.Time { return now }
+	for _, name := range []string{"a", "b", "c"} {
+		_, err = fs.Snapshot(name, false)
+		ok(t, err)
+		now = now.Add(24 * time.Hour)
+	}
+
+	policy := retention.Policy{Latest: 1, Location: time.UTC}
+	want := map[string]string{"tank/fs@c": "latest", "tank/fs@b": "", "tank/fs@a": ""}
+	for name, list := range map[string]func(...string) ([]*zfs.Dataset, error){
+		"Dataset": fs.Snapshots,
+		"Zpool":   pool.Snapshots,
+	} {
+		snapshots, err := list(retention.Properties...)
+		ok(t, err)
+		plan, err := policy.Plan(snapshots)
+		ok(t, err)
+		if got := kept(plan); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: wanted %v, got %v", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// SendOptions are the options of Dataset.Send, each but Progress corresponding to a flag of `zfs send`. The zero
+// value sends a full stream of a single snapshot, like SendSnapshot.
+//
+// Options which the installed ZFS version does not support make Send fail with an error matching ErrUnsupported
+// before any command is run.
+type SendOptions struct {
+	// Base is the snapshot or bookmark an incremental stream is generated from (-i), or nil for a full stream. With
+	// Replicate, the snapshots of the same name of all descendants are used as their bases.
+	Base *Dataset
+	// Intermediate includes all snapshots between Base and the sent snapshot in the stream (-I). Base must be a
+	// snapshot.
+	Intermediate bool
+
+	// Replicate sends the dataset along with all its descendants, their snapshots and properties (-R).
+	Replicate bool
+	// Props includes the properties of the dataset in the stream (-p).
+	Props bool
+	// Holds includes the user holds of the snapshots in the stream, to be placed on the received snapshots (-h).
+	Holds bool
+	// Backup includes the received values of properties rather than their local values (-b).
+	Backup bool
+
+	// Raw sends the blocks of encrypted datasets as they are stored on disk, without decrypting them (-w). Raw
+	// streams keep the compression and block sizes of the source, as with Compressed, LargeBlocks and EmbeddedData.
+	Raw bool
+	// Compressed sends compressed blocks as they are stored on disk, without decompressing them (-c).
+	Compressed bool
+	// LargeBlocks allows blocks larger than 128K in the stream (-L).
+	LargeBlocks bool
+	// EmbeddedData sends blocks embedded in block pointers as is (-e).
+	EmbeddedData bool
+	// Dedup generates a deduplicated stream (-D). Deduplicated sends were removed in OpenZFS 2.1, which always
+	// generates dedup-free streams, so Dedup fails with an error matching ErrUnsupported there.
+	Dedup bool
+
+	// Saved sends the state saved by an interrupted `zfs receive -s` of the receiving filesystem or volume, rather
+	// than a snapshot (-S). It cannot be combined with Base or Replicate.
+	Saved bool
+
+	// Progress, if not nil, enables progress reporting of the stream.
+	Progress *ProgressOptions
+}
+
+// args returns the flags of `zfs send` for o.
+func (o *SendOptions) args() []string {
+	var args []string
+	for _, f := range []struct {
+		set  bool
+		flag string
+	}{
+		{o.Replicate, "-R"},
+		{o.Props, "-p"},
+		{o.Holds, "-h"},
+		{o.Backup, "-b"},
+		{o.Raw, "-w"},
+		{o.Compressed, "-c"},
+		{o.LargeBlocks, "-L"},
+		{o.EmbeddedData, "-e"},
+		{o.Dedup, "-D"},
+		{o.Saved, "-S"},
+	} {
+		if f.set {
+			args = append(args, f.flag)
+		}
+	}
+	if o.Base != nil {
+		if o.Intermediate {
+			args = append(args, "-I", o.Base.Name)
+		} else {
+			args = append(args, "-i", o.Base.Name)
+		}
+	}
+	return args
+}
+
+// checkSend returns an error if o cannot be used to send d with the installed ZFS version.
+func (c *Client) checkSend(ctx context.Context, d *Dataset, o *SendOptions) error {
+	switch {
+	case o.Saved && d.Type != DatasetFilesystem && d.Type != DatasetVolume:
+		return errors.New("can only send the saved state of filesystems and volumes")
+	case o.Saved && (o.Base != nil || o.Replicate):
+		return errors.New("saved sends cannot be incremental or replicated")
+	case !o.Saved && d.Type != DatasetSnapshot:
+		return errors.New("can only send snapshots")
+	case o.Base != nil && o.Base.Type != DatasetSnapshot && o.Base.Type != DatasetBookmark:
+		return errors.New("can only send incrementally from snapshots and bookmarks")
+	case o.Intermediate && o.Base == nil:
+		return errors.New("intermediate sends require a base snapshot")
+	case o.Intermediate && o.Base.Type != DatasetSnapshot:
+		return errors.New("intermediate sends cannot start from a bookmark")
+	}
+
+	for _, f := range []struct {
+		set     bool
+		feature Feature
+	}{
+		{o.Compressed, FeatureCompressedSend},
+		{o.Raw, FeatureRawSend},
+		{o.Backup, FeatureSendBackup},
+		{o.Holds, FeatureSendHolds},
+		{o.Saved, FeatureSendSaved},
+	} {
+		if !f.set {
+			continue
+		}
+		if err := c.require(ctx, f.feature); err != nil {
+			return err
+		}
+	}
+	if o.Dedup {
+		if v, err := c.detectVersion(ctx, false); err == nil && v.AtLeast(2, 1, 0) {
+			return fmt.Errorf("%w: deduplicated sends were removed in OpenZFS 2.1.0, found %s", ErrUnsupported, v)
+		}
+	}
+	return nil
+}
+
+// Send sends a ZFS stream of the receiving snapshot to the input io.Writer, as specified by opts.
+// An error will be returned if the input dataset is not of snapshot type, or of filesystem or volume type for saved
+// sends.
+func (d *Dataset) Send(output io.Writer, opts SendOptions) error {
+	return d.SendContext(context.Background(), output, opts)
+}
+
+// SendContext is like Send but includes a context.
+func (d *Dataset) SendContext(ctx context.Context, output io.Writer, opts SendOptions) error {
+	if err := d.client.checkSend(ctx, d, &opts); err != nil {
+		return err
+	}
+	if opts.Progress != nil && opts.Progress.Func != nil {
+		total := opts.Progress.Total
+		if total == 0 {
+			if estimate, err := d.EstimateSendContext(ctx, opts); err == nil {
+				total = estimate.Size
+			}
+		}
+		p := startProgress(opts.Progress, total)
+		defer p.finish()
+		output = &progressWriter{w: output, p: p}
+	}
+
+	args := append([]string{"send"}, opts.args()...)
+	c := command{Command: d.client.zfsPath(), Stdout: output, client: d.client}
+	_, err := c.RunContext(ctx, append(args, d.Name)...)
+	return err
+}
+
+// StreamEstimate is the estimated size of the stream of a single snapshot, part of a SendEstimate.
+type StreamEstimate struct {
+	// Snapshot is the name of the sent snapshot.
+	Snapshot string
+	// Base is the incremental source of the stream as printed by zfs, which may omit the dataset name, or empty for
+	// a full stream.
+	Base string
+	// Size is the estimated size of the stream, in bytes.
+	Size uint64
+}
+
+// SendEstimate is the estimated size of a send stream, as reported by Dataset.EstimateSend.
+type SendEstimate struct {
+	// Streams are the estimates of the streams of each snapshot, in the order they would be sent.
+	Streams []StreamEstimate
+	// Size is the estimated size of the whole stream, in bytes.
+	Size uint64
+}
+
+// parseSendEstimate parses the output of `zfs send -nvP`, such as:
+//
+//	full	tank/fs@a	1207656
+//	incremental	a	tank/fs@b	4192
+//	size	1211848
+func parseSendEstimate(out [][]string) (*SendEstimate, error) {
+	e := &SendEstimate{}
+	total := false
+	for _, line := range out {
+		var s StreamEstimate
+		var size string
+		switch {
+		case line[0] == "full" && len(line) == 3:
+			s.Snapshot, size = line[1], line[2]
+		case line[0] == "incremental" && len(line) == 4:
+			s.Base, s.Snapshot, size = line[1], line[2], line[3]
+		case line[0] == "size" && len(line) == 2:
+			size = line[1]
+		default:
+			continue
+		}
+		n, err := strconv.ParseUint(size, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size in output of zfs send: %q", line)
+		}
+		if line[0] == "size" {
+			e.Size, total = n, true
+			continue
+		}
+		s.Size = n
+		e.Streams = append(e.Streams, s)
+	}
+	if len(e.Streams) == 0 && !total {
+		return nil, errors.New("no estimate found in the output of zfs send")
+	}
+	if !total {
+		for _, s := range e.Streams {
+			e.Size += s.Size
+		}
+	}
+	return e, nil
+}
+
+// EstimateSend returns the estimated size of the stream Send would generate with opts, using `zfs send -nvP`.
+func (d *Dataset) EstimateSend(opts SendOptions) (*SendEstimate, error) {
+	return d.EstimateSendContext(context.Background(), opts)
+}
+
+// EstimateSendContext is like EstimateSend but includes a context.
+func (d *Dataset) EstimateSendContext(ctx context.Context, opts SendOptions) (*SendEstimate, error) {
+	if err := d.client.checkSend(ctx, d, &opts); err != nil {
+		return nil, err
+	}
+	args := append([]string{"send", "-n", "-v", "-P"}, opts.args()...)
+	out, err := d.client.zfsOutput(ctx, append(args, d.Name)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseSendEstimate(out)
+}
@@ -0,0 +1,129 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// versionClient returns a client reporting the given ZFS version, which records the arguments of other commands.
+func versionClient(version string, args *[]string) *Client {
+	return &Client{Runner: &Runner{
+		Executor: execFunc(func(ctx context.Context, cmd *Cmd) error {
+			if len(cmd.Args) > 0 && cmd.Args[0] == "version" {
+				_, err := io.WriteString(cmd.Stdout, "zfs-"+version+"-1\nzfs-kmod-"+version+"-1\n")
+				return err
+			}
+			*args = cmd.Args
+			return nil
+		}),
+	}}
+}
+
+func TestSendOptions(t *testing.T) {
+	var args []string
+	client := versionClient("2.1.5", &args)
+	fs := &Dataset{Name: "tank/fs", Type: DatasetFilesystem, client: client}
+	base := &Dataset{Name: "tank/fs@a", Type: DatasetSnapshot, client: client}
+	bookmark := &Dataset{Name: "tank/fs#a", Type: DatasetBookmark, client: client}
+	snap := &Dataset{Name: "tank/fs@b", Type: DatasetSnapshot, client: client}
+
+	for _, tt := range []struct {
+		name string
+		ds   *Dataset
+		opts SendOptions
+		want string
+	}{
+		{"full", snap, SendOptions{}, "send tank/fs@b"},
+		{"incremental", snap, SendOptions{Base: bookmark}, "send -i tank/fs#a tank/fs@b"},
+		{"replicate", snap, SendOptions{Base: base, Intermediate: true, Replicate: true, Raw: true}, "send -R -w -I tank/fs@a tank/fs@b"},
+		{"flags", snap, SendOptions{Props: true, Holds: true, Backup: true, Compressed: true, LargeBlocks: true, EmbeddedData: true}, "send -p -h -b -c -L -e tank/fs@b"},
+		{"saved", fs, SendOptions{Saved: true}, "send -S tank/fs"},
+	} {
+		args = nil
+		if err := tt.ds.Send(ioutil.Discard, tt.opts); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := strings.Join(args, " "); got != tt.want {
+			t.Errorf("%s: got %q, wanted %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		ds   *Dataset
+		opts SendOptions
+	}{
+		{"filesystem", fs, SendOptions{}},
+		{"saved snapshot", snap, SendOptions{Saved: true}},
+		{"saved incremental", fs, SendOptions{Saved: true, Base: base}},
+		{"intermediate without base", snap, SendOptions{Intermediate: true}},
+		{"intermediate bookmark", snap, SendOptions{Base: bookmark, Intermediate: true}},
+		{"filesystem base", snap, SendOptions{Base: fs}},
+	} {
+		args = nil
+		if err := tt.ds.Send(ioutil.Discard, tt.opts); err == nil || errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: wanted a validation error, got %v", tt.name, err)
+		}
+		if args != nil {
+			t.Errorf("%s: command was run: %q", tt.name, args)
+		}
+	}
+
+	if err := snap.Send(ioutil.Discard, SendOptions{Dedup: true}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("dedup: wanted ErrUnsupported, got %v", err)
+	}
+	client = versionClient("0.8.6", &args)
+	snap.client = client
+	if err := snap.Send(ioutil.Discard, SendOptions{Holds: true}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("holds: wanted ErrUnsupported, got %v", err)
+	}
+	args = nil
+	if err := snap.Send(ioutil.Discard, SendOptions{Dedup: true, Raw: true}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"send", "-w", "-D", "tank/fs@b"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("got %q, wanted %q", args, want)
+	}
+}
+
+func TestParseSendEstimate(t *testing.T) {
+	e, err := parseSendEstimate([][]string{
+		{"full", "tank/fs@a", "1207656"},
+		{"incremental", "a", "tank/fs@b", "4192"},
+		{"size", "1211850"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &SendEstimate{
+		Streams: []StreamEstimate{
+			{Snapshot: "tank/fs@a", Size: 1207656},
+			{Snapshot: "tank/fs@b", Base: "a", Size: 4192},
+		},
+		Size: 1211850,
+	}
+	if !reflect.DeepEqual(e, want) {
+		t.Fatalf("got %+v, wanted %+v", e, want)
+	}
+
+	e, err = parseSendEstimate([][]string{{"resume token contents:"}, {"full", "tank/fs@a", "100"}, {"full", "tank/fs/child@a", "50"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Size != 150 {
+		t.Fatalf("wanted the sum of the streams without a size line, got %d", e.Size)
+	}
+
+	if _, err := parseSendEstimate([][]string{{"full", "tank/fs@a", "many"}}); err == nil {
+		t.Fatal("wanted an error for an invalid size")
+	}
+	if _, err := parseSendEstimate(nil); err == nil {
+		t.Fatal("wanted an error for empty output")
+	}
+}
@@ -0,0 +1,120 @@
+package zfs
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Size is a number of bytes, such as the value of the quota, reservation, refquota or volsize properties.
+//
+// Sizes are parsed and formatted like the ZFS tools do, with binary unit suffixes: 1K is 1024 bytes.
+//
+// Size is only used by DatasetProperties. CreateVolume and the Volsize and Quota fields of Dataset keep plain
+// uint64 byte counts, to which a Size converts with uint64(size).
+type Size uint64
+
+// Common sizes.
+const (
+	Byte     Size = 1
+	Kibibyte      = 1024 * Byte
+	Mebibyte      = 1024 * Kibibyte
+	Gibibyte      = 1024 * Mebibyte
+	Tebibyte      = 1024 * Gibibyte
+	Pebibyte      = 1024 * Tebibyte
+	Exbibyte      = 1024 * Pebibyte
+)
+
+const sizeUnits = "BKMGTPE"
+
+// ParseSize parses a size such as "10G", "1.5T", "512K", "512KiB" or "4096". The unit is case insensitive and may be
+// followed by "B" or "iB". "none" and "-", used by ZFS for unset limits, parse as 0.
+//
+// Fractions of a unit are accepted, as the ZFS tools print sizes rounded to a few digits, but a fraction of a byte
+// is an error rather than being truncated, so that "0.5" or "0.1K" are not taken for a size of 0.
+func ParseSize(s string) (Size, error) {
+	if s == "none" || s == "-" {
+		return 0, nil
+	}
+
+	num := s
+	shift := 0
+	if i := strings.IndexFunc(s, func(r rune) bool { return !(r >= '0' && r <= '9' || r == '.') }); i >= 0 {
+		num = s[:i]
+		unit := strings.ToUpper(s[i:])
+		shift = strings.IndexByte(sizeUnits, unit[0])
+		switch rest := unit[1:]; {
+		case shift < 0:
+			return 0, fmt.Errorf("invalid size %q: unknown unit", s)
+		case rest == "", shift > 0 && (rest == "B" || rest == "IB"):
+		default:
+			return 0, fmt.Errorf("invalid size %q: unknown unit", s)
+		}
+	}
+	if num == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	if !strings.Contains(num, ".") {
+		n, err := strconv.ParseUint(num, 10, 64)
+		if err != nil || shift > 0 && n > math.MaxUint64>>(10*shift) {
+			return 0, fmt.Errorf("invalid size %q: value is too large", s)
+		}
+		return Size(n << (10 * shift)), nil
+	}
+	if shift == 0 {
+		return 0, fmt.Errorf("invalid size %q: not a whole number of bytes", s)
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	f *= math.Pow(2, float64(10*shift))
+	if f > 0 && f < 1 {
+		return 0, fmt.Errorf("invalid size %q: not a whole number of bytes", s)
+	}
+	if f >= math.MaxUint64 {
+		return 0, fmt.Errorf("invalid size %q: value is too large", s)
+	}
+	return Size(f), nil
+}
+
+// String formats s the way the ZFS tools print sizes, such as "512", "128K" or "1.50G".
+func (s Size) String() string {
+	i := 0
+	for i < len(sizeUnits)-1 && s >= 1<<(10*(i+1)) {
+		i++
+	}
+	if i == 0 {
+		return strconv.FormatUint(uint64(s), 10)
+	}
+	unit := sizeUnits[i : i+1]
+	if s&(1<<(10*i)-1) == 0 {
+		return strconv.FormatUint(uint64(s>>(10*i)), 10) + unit
+	}
+	v := float64(s) / float64(uint64(1)<<(10*i))
+	var str string
+	for prec := 2; prec >= 0; prec-- {
+		str = strconv.FormatFloat(v, 'f', prec, 64) + unit
+		if len(str) <= 5 {
+			break
+		}
+	}
+	return str
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Size) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Size) UnmarshalText(text []byte) error {
+	v, err := ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
@@ -0,0 +1,57 @@
+package zfs
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]Size{
+		"0":     0,
+		"4096":  4096,
+		"512B":  512,
+		"512K":  512 * Kibibyte,
+		"512k":  512 * Kibibyte,
+		"10G":   10 * Gibibyte,
+		"10GB":  10 * Gibibyte,
+		"10GiB": 10 * Gibibyte,
+		"1.5T":  Tebibyte + Tebibyte/2,
+		"1.23K": 1259,
+		"0.0K":  0,
+		"none":  0,
+		"-":     0,
+	} {
+		got, err := ParseSize(in)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", in, err)
+		} else if got != want {
+			t.Errorf("%q: got %d, wanted %d", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "G", "10X", "10BB", "1.2.3K", "-5", "10 G", "16E", "16.5E", "1.5", "0.5", "2.5B", "0.0001K"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("%q: wanted an error", in)
+		}
+	}
+}
+
+func TestSizeString(t *testing.T) {
+	for size, want := range map[Size]string{
+		0:                     "0",
+		512:                   "512",
+		1024:                  "1K",
+		1536:                  "1.50K",
+		128 * Kibibyte:        "128K",
+		10 * Gibibyte:         "10G",
+		Tebibyte + Tebibyte/2: "1.50T",
+		100*Gibibyte + 1:      "100G",
+		123456789:             "118M",
+	} {
+		if got := size.String(); got != want {
+			t.Errorf("%d: got %q, wanted %q", uint64(size), got, want)
+		}
+		if size%Kibibyte == 0 {
+			if parsed, err := ParseSize(want); err != nil || parsed != size {
+				t.Errorf("%q does not round trip: %d, %v", want, parsed, err)
+			}
+		}
+	}
+}
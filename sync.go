@@ -0,0 +1,218 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoCommonSnapshot is returned by Dataset.Sync when the source and the existing target have no snapshot in
+// common, with the NoCommonFail policy.
+var ErrNoCommonSnapshot = errors.New("no common snapshot")
+
+// NoCommonPolicy is what Dataset.Sync does when the target exists but has no snapshot in common with the source.
+type NoCommonPolicy int
+
+// Policies for targets without a snapshot in common with the source.
+const (
+	// NoCommonFail fails with ErrNoCommonSnapshot, leaving the target untouched.
+	NoCommonFail NoCommonPolicy = iota
+	// NoCommonFullSend sends a full stream, then destroys the target along with its snapshots and descendants and
+	// replaces it with the received dataset.
+	NoCommonFullSend
+	// NoCommonRename sends a full stream, then renames the target out of the way, by appending the current time to
+	// its name, and replaces it with the received dataset.
+	NoCommonRename
+)
+
+// SyncOptions are the options of Dataset.Sync.
+type SyncOptions struct {
+	// Snapshot, if not empty, is the name of a snapshot of the source created before sending, so that the target
+	// is brought up to date with the current state of the source. It is not created when Sync fails beforehand,
+	// such as with ErrNoCommonSnapshot.
+	Snapshot string
+	// NoCommon is the policy applied when the target exists but has no snapshot in common with the source.
+	NoCommon NoCommonPolicy
+
+	// Send and Receive are the options of the streams. Sync sets the base of incremental streams itself, and works on
+	// a single dataset: Base, Replicate and Saved, as well as DiscardFirst and LastElement, cannot be used.
+	Send    SendOptions
+	Receive ReceiveOptions
+}
+
+// SyncResult is the outcome of Dataset.Sync.
+type SyncResult struct {
+	// Common is the newest snapshot of the source which the target had, or nil if a full stream was sent.
+	Common *Dataset
+	// Latest is the newest snapshot of the source, which the target now has.
+	Latest *Dataset
+	// Renamed is the name the target was renamed to with the NoCommonRename policy, or empty.
+	Renamed string
+	// Streams are the streams received, none if the target was already up to date. When the target was replaced,
+	// they were received under a temporary name.
+	Streams []ReceivedStream
+}
+
+// snapshotsByGUID returns the snapshots of the dataset name, excluding those of its descendants, oldest first, along
+// with their guid.
+func (c *Client) snapshotsByGUID(ctx context.Context, name string) ([]*Dataset, error) {
+	all, err := c.SnapshotsContext(ctx, name, "guid", "createtxg")
+	if err != nil {
+		return nil, err
+	}
+	snapshots := all[:0]
+	for _, s := range all {
+		if strings.HasPrefix(s.Name, name+"@") {
+			snapshots = append(snapshots, s)
+		}
+	}
+	txg := func(s *Dataset) uint64 {
+		n, _ := strconv.ParseUint(s.Extra["createtxg"], 10, 64)
+		return n
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return txg(snapshots[i]) < txg(snapshots[j]) })
+	return snapshots, nil
+}
+
+// Sync brings the dataset target, managed by the client dst, up to date with the latest snapshot of the receiving
+// filesystem or volume, like syncoid. The newest snapshot of the source found on the target, matched by guid so that
+// renamed snapshots are recognized, is used as the base of an intermediate stream (-I) carrying all later snapshots.
+//
+// When target does not exist, a full stream of the oldest snapshot of the source is sent first. When it exists
+// without a snapshot in common with the source, opts.NoCommon decides whether to fail or to replace it. The
+// replacement is received next to the target, under its name followed by "-syncing-" and the current time, and the
+// target is only destroyed or renamed once the transfer succeeded.
+//
+// A target modified since the common snapshot, or with snapshots newer than it, makes the receive fail unless
+// opts.Receive.Force is set, in which case the target is rolled back.
+func (d *Dataset) Sync(dst *Client, target string, opts SyncOptions) (*SyncResult, error) {
+	return d.SyncContext(context.Background(), dst, target, opts)
+}
+
+// SyncContext is like Sync but includes a context.
+func (d *Dataset) SyncContext(ctx context.Context, dst *Client, target string, opts SyncOptions) (*SyncResult, error) {
+	switch {
+	case d.Type != DatasetFilesystem && d.Type != DatasetVolume:
+		return nil, errors.New("can only sync filesystems and volumes")
+	case strings.Contains(target, "@"):
+		return nil, errors.New("the target of a sync must be a filesystem or volume")
+	case opts.Send.Base != nil || opts.Send.Replicate || opts.Send.Saved:
+		return nil, errors.New("Base, Replicate and Saved cannot be used to sync")
+	case opts.Receive.DiscardFirst || opts.Receive.LastElement:
+		return nil, errors.New("DiscardFirst and LastElement cannot be used to sync")
+	}
+
+	snapshots, err := d.client.snapshotsByGUID(ctx, d.Name)
+	if err != nil {
+		return nil, err
+	}
+	exists := true
+	targetSnapshots, err := dst.snapshotsByGUID(ctx, target)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		exists = false
+	}
+	guids := make(map[string]bool, len(targetSnapshots))
+	for _, s := range targetSnapshots {
+		guids[s.Extra["guid"]] = true
+	}
+	res := &SyncResult{}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if guids[snapshots[i].Extra["guid"]] {
+			res.Common = snapshots[i]
+			break
+		}
+	}
+
+	// A target without a common snapshot is replaced by a dataset received under a temporary name, so that it is
+	// only destroyed or renamed once the new one is complete.
+	received := target
+	stamp := time.Now().Format("20060102150405")
+	if res.Common == nil && exists {
+		if opts.NoCommon != NoCommonFullSend && opts.NoCommon != NoCommonRename {
+			return nil, fmt.Errorf("%w between %s and %s", ErrNoCommonSnapshot, d.Name, target)
+		}
+		received = target + "-syncing-" + stamp
+	}
+
+	// The new snapshot cannot be on the target, so it does not change the common snapshot.
+	if opts.Snapshot != "" {
+		if _, err := d.SnapshotContext(ctx, opts.Snapshot, false); err != nil {
+			return nil, err
+		}
+		if snapshots, err = d.client.snapshotsByGUID(ctx, d.Name); err != nil {
+			return nil, err
+		}
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshot of %s to sync", d.Name)
+	}
+	res.Latest = snapshots[len(snapshots)-1]
+
+	if err := syncStreams(ctx, dst, snapshots[0], res, received, opts); err != nil {
+		if received != target {
+			tmp := &Dataset{Name: received, client: dst}
+			if derr := tmp.DestroyContext(ctx, DestroyRecursive); derr != nil && !errors.Is(derr, ErrNotFound) {
+				return nil, fmt.Errorf("%w (and destroying %s failed: %v)", err, received, derr)
+			}
+		}
+		return nil, err
+	}
+	if received == target {
+		return res, nil
+	}
+
+	old := &Dataset{Name: target, client: dst}
+	if opts.NoCommon == NoCommonRename {
+		res.Renamed = target + "-" + stamp
+		_, err = old.RenameContext(ctx, res.Renamed, false, false)
+	} else {
+		err = old.DestroyContext(ctx, DestroyRecursive)
+	}
+	if err == nil {
+		_, err = (&Dataset{Name: received, client: dst}).RenameContext(ctx, target, false, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("replacing %s with %s: %w", target, received, err)
+	}
+	return res, nil
+}
+
+// syncStreams sends the snapshots of the source into name, incrementally from res.Common if set, and otherwise with
+// a full stream of oldest first.
+func syncStreams(ctx context.Context, dst *Client, oldest *Dataset, res *SyncResult, name string, opts SyncOptions) error {
+	base := res.Common
+	if base == nil {
+		base = oldest
+		streams, err := syncStream(ctx, dst, base, name, opts, nil)
+		if err != nil {
+			return err
+		}
+		res.Streams = append(res.Streams, streams...)
+	}
+	if base.Name != res.Latest.Name {
+		streams, err := syncStream(ctx, dst, res.Latest, name, opts, base)
+		if err != nil {
+			return err
+		}
+		res.Streams = append(res.Streams, streams...)
+	}
+	return nil
+}
+
+// syncStream sends snapshot into target, incrementally from base with all intermediate snapshots if base is not nil.
+func syncStream(ctx context.Context, dst *Client, snapshot *Dataset, target string, opts SyncOptions, base *Dataset) ([]ReceivedStream, error) {
+	opts.Send.Base = base
+	opts.Send.Intermediate = base != nil
+	res, err := dst.ReplicateContext(ctx, snapshot, target, ReplicateOptions{Send: opts.Send, Receive: opts.Receive})
+	if err != nil {
+		return nil, err
+	}
+	return res.Streams, nil
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"math"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"runtime"
@@ -12,8 +13,15 @@ import (
 	"time"
 
 	"github.com/mistifyio/go-zfs/v4"
+	"github.com/mistifyio/go-zfs/v4/zfstest"
 )
 
+// emulated is true when no ZFS installation is available, in which case the tests run against the zfstest emulator.
+var emulated = func() bool {
+	_, err := exec.LookPath("zpool")
+	return err != nil
+}()
+
 func sleep(delay int) {
 	time.Sleep(time.Duration(delay) * time.Second)
 }
@@ -67,6 +75,12 @@ func (f cleanUpFunc) cleanUp() {
 func setupZPool(t *testing.T) cleanUpFunc {
 	t.Helper()
 
+	if emulated {
+		runner := zfs.Default()
+		zfs.SetRunner(&zfs.Runner{Executor: zfstest.NewEmulator()})
+		t.Cleanup(func() { zfs.SetRunner(runner) })
+	}
+
 	d, err := ioutil.TempDir("/tmp/", "zfs-test-*")
 	ok(t, err)
 
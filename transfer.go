@@ -0,0 +1,122 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ReplicationError is returned when piping a send into a receive fails, with the errors of both sides.
+type ReplicationError struct {
+	// Err is the error of the side which failed first, Send or Receive.
+	Err error
+	// Send and Receive are the errors of the send and of the receive, nil for a side which succeeded. The side which
+	// did not fail first usually fails as a consequence, either killed or with an incomplete stream.
+	Send    error
+	Receive error
+
+	// SendStderr and ReceiveStderr are the standard error outputs of `zfs send` and `zfs receive`, if they failed.
+	SendStderr    string
+	ReceiveStderr string
+}
+
+func (e *ReplicationError) Error() string {
+	var parts []string
+	if e.Send != nil {
+		parts = append(parts, "send: "+e.Send.Error())
+	}
+	if e.Receive != nil {
+		parts = append(parts, "receive: "+e.Receive.Error())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Is reports whether the error of either side matches target.
+func (e *ReplicationError) Is(target error) bool {
+	return errors.Is(e.Send, target) || errors.Is(e.Receive, target)
+}
+
+// Unwrap returns the error of the side which failed first.
+func (e *ReplicationError) Unwrap() error {
+	return e.Err
+}
+
+// stderr returns the standard error output of the command which failed with err, if any.
+func stderr(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Stderr
+	}
+	return ""
+}
+
+// transfer runs send and receive concurrently, the output of send being piped into receive, and returns a
+// *ReplicationError if either failed. When receive fails, send is canceled, as it would otherwise block writing to
+// the pipe. When send fails, the pipe is closed, which ends receive with an incomplete stream.
+//
+// When local is true, both sides run as local processes and are connected by an OS pipe: the write end becomes the
+// standard output of `zfs send` and the read end the standard input of `zfs receive`, so that the stream goes from
+// one process to the other without being copied by Go. Otherwise, such as with a RemoteExecutor or an emulator, the
+// sides are connected by an io.Pipe.
+func transfer(ctx context.Context, local bool, send func(context.Context, io.Writer) error, receive func(context.Context, io.Reader) error) error {
+	sendCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var r io.ReadCloser
+	var w io.WriteCloser
+	if local {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		r, w = pr, pw
+	} else {
+		r, w = io.Pipe()
+	}
+
+	var once sync.Once
+	var first error
+	fail := func(err error) {
+		once.Do(func() { first = err })
+	}
+
+	var sendErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sendErr = send(sendCtx, w)
+		if sendErr != nil {
+			fail(sendErr)
+		}
+		if pw, ok := w.(*io.PipeWriter); ok {
+			pw.CloseWithError(sendErr)
+		} else {
+			w.Close()
+		}
+	}()
+
+	recvErr := receive(ctx, r)
+	if recvErr != nil {
+		fail(recvErr)
+		cancel()
+	}
+	if pr, ok := r.(*io.PipeReader); ok {
+		pr.CloseWithError(io.ErrClosedPipe)
+	} else {
+		r.Close()
+	}
+	<-done
+	if first == nil {
+		return nil
+	}
+	return &ReplicationError{
+		Err:           first,
+		Send:          sendErr,
+		Receive:       recvErr,
+		SendStderr:    stderr(sendErr),
+		ReceiveStderr: stderr(recvErr),
+	}
+}
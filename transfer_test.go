@@ -0,0 +1,85 @@
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestTransfer(t *testing.T) {
+	for _, local := range []bool{false, true} {
+		sendErr := newError(errors.New("exit status 1"), "zfs send tank/fs@snap", "cannot open 'tank/fs@snap': dataset does not exist", "")
+		err := transfer(context.Background(), local, func(ctx context.Context, w io.Writer) error {
+			if _, err := io.WriteString(w, "partial"); err != nil {
+				return err
+			}
+			return sendErr
+		}, func(ctx context.Context, r io.Reader) error {
+			_, err := ioutil.ReadAll(r)
+			return err
+		})
+		var rerr *ReplicationError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("local=%v: wanted a *ReplicationError, got %v", local, err)
+		}
+		// An OS pipe only ends with EOF: the receive of a truncated stream is left to fail on its own.
+		wantRecvErr := !local
+		if rerr.Err != sendErr || (rerr.Receive != nil) != wantRecvErr || rerr.SendStderr != sendErr.Stderr || !errors.Is(err, ErrNotFound) {
+			t.Fatalf("local=%v: unexpected error for a failed send: %+v", local, rerr)
+		}
+
+		recvErr := newError(errors.New("exit status 1"), "zfs receive tank/copy", "cannot receive: failed to read from stream", "")
+		err = transfer(context.Background(), local, func(ctx context.Context, w io.Writer) error {
+			for ctx.Err() == nil {
+				if _, err := w.Write(make([]byte, 512)); err != nil {
+					return err
+				}
+			}
+			return ctx.Err()
+		}, func(ctx context.Context, r io.Reader) error {
+			return recvErr
+		})
+		if !errors.As(err, &rerr) {
+			t.Fatalf("local=%v: wanted a *ReplicationError, got %v", local, err)
+		}
+		if rerr.Err != recvErr || rerr.Send == nil || rerr.ReceiveStderr != recvErr.Stderr || rerr.SendStderr != "" {
+			t.Fatalf("local=%v: unexpected error for a failed receive: %+v", local, rerr)
+		}
+
+		if err := transfer(context.Background(), local, func(ctx context.Context, w io.Writer) error {
+			_, err := io.WriteString(w, "stream")
+			return err
+		}, func(ctx context.Context, r io.Reader) error {
+			_, err := ioutil.ReadAll(r)
+			return err
+		}); err != nil {
+			t.Fatalf("local=%v: %v", local, err)
+		}
+	}
+}
+
+func TestTransferLocal(t *testing.T) {
+	var out bytes.Buffer
+	executor := &LocalExecutor{}
+	err := transfer(context.Background(), true, func(ctx context.Context, w io.Writer) error {
+		if _, ok := w.(*os.File); !ok {
+			t.Errorf("wanted the send to write to an *os.File, got %T", w)
+		}
+		return executor.Exec(ctx, &Cmd{Path: "echo", Args: []string{"stream"}, Stdout: w})
+	}, func(ctx context.Context, r io.Reader) error {
+		if _, ok := r.(*os.File); !ok {
+			t.Errorf("wanted the receive to read from an *os.File, got %T", r)
+		}
+		return executor.Exec(ctx, &Cmd{Path: "cat", Stdin: r, Stdout: &out})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "stream\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
@@ -0,0 +1,152 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Limits on user properties enforced by ZFS.
+const (
+	maxUserPropertyNameLen  = 255
+	maxUserPropertyValueLen = 8191
+)
+
+// ValidateUserProperty checks that name follows the rules ZFS imposes on user property names: a module, such as a
+// reversed domain name, and a property separated by a colon, using only lowercase letters, digits and the characters
+// ':', '.', '_' and '-', at most 255 characters long. The returned error matches ErrInvalidProperty.
+func ValidateUserProperty(name string) error {
+	i := strings.IndexByte(name, ':')
+	switch {
+	case i < 0:
+		return fmt.Errorf("%w: user property %q must be of the form module:property", ErrInvalidProperty, name)
+	case i == 0:
+		return fmt.Errorf("%w: user property %q has an empty module", ErrInvalidProperty, name)
+	case len(name) > maxUserPropertyNameLen:
+		return fmt.Errorf("%w: user property %q is longer than %d characters", ErrInvalidProperty, name, maxUserPropertyNameLen)
+	case name[0] == '-':
+		return fmt.Errorf("%w: user property %q starts with '-'", ErrInvalidProperty, name)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || strings.ContainsRune(":._-", r)) {
+			return fmt.Errorf("%w: user property %q contains invalid character %q", ErrInvalidProperty, name, r)
+		}
+	}
+	return nil
+}
+
+// isUserProperty reports whether name is a user property rather than a native one.
+func isUserProperty(name string) bool {
+	return strings.IndexByte(name, ':') > 0
+}
+
+// SetUserProperty validates and sets a user property on the receiving dataset.
+func (d *Dataset) SetUserProperty(name, value string) error {
+	return d.SetUserPropertyContext(context.Background(), name, value)
+}
+
+// SetUserPropertyContext is like SetUserProperty but includes a context.
+func (d *Dataset) SetUserPropertyContext(ctx context.Context, name, value string) error {
+	if err := ValidateUserProperty(name); err != nil {
+		return err
+	}
+	if len(value) > maxUserPropertyValueLen {
+		return fmt.Errorf("%w: value of user property %q is longer than %d bytes", ErrInvalidProperty, name, maxUserPropertyValueLen)
+	}
+	return d.SetPropertyContext(ctx, name, value)
+}
+
+// GetUserProperty returns the value of a user property of the receiving dataset, either set locally, inherited or
+// received. ok is false if the property is not set.
+func (d *Dataset) GetUserProperty(name string) (value string, ok bool, err error) {
+	return d.GetUserPropertyContext(context.Background(), name)
+}
+
+// GetUserPropertyContext is like GetUserProperty but includes a context.
+func (d *Dataset) GetUserPropertyContext(ctx context.Context, name string) (value string, ok bool, err error) {
+	if err := ValidateUserProperty(name); err != nil {
+		return "", false, err
+	}
+	props, err := d.GetPropertiesContext(ctx, name)
+	if err != nil {
+		return "", false, err
+	}
+	p, ok := props[name]
+	if !ok || p.Source == SourceNone {
+		return "", false, nil
+	}
+	return p.Value, true, nil
+}
+
+// UnsetUserProperty removes a user property set on the receiving dataset, using `zfs inherit`. The dataset then
+// inherits the value of its closest ancestor with the property set, if any.
+// If recursive is true, the property is also removed from all descendants.
+func (d *Dataset) UnsetUserProperty(name string, recursive bool) error {
+	return d.UnsetUserPropertyContext(context.Background(), name, recursive)
+}
+
+// UnsetUserPropertyContext is like UnsetUserProperty but includes a context.
+func (d *Dataset) UnsetUserPropertyContext(ctx context.Context, name string, recursive bool) error {
+	if err := ValidateUserProperty(name); err != nil {
+		return err
+	}
+	return d.InheritPropertyContext(ctx, name, recursive, false)
+}
+
+// UserProperties returns the user properties of the receiving dataset whose module is namespace, such as
+// "com.example", keyed by property name. If namespace is empty, all user properties are returned.
+func (d *Dataset) UserProperties(namespace string) (map[string]Property, error) {
+	return d.UserPropertiesContext(context.Background(), namespace)
+}
+
+// UserPropertiesContext is like UserProperties but includes a context.
+func (d *Dataset) UserPropertiesContext(ctx context.Context, namespace string) (map[string]Property, error) {
+	props, err := d.GetAllPropertiesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for name := range props {
+		if !isUserProperty(name) || namespace != "" && !strings.HasPrefix(name, namespace+":") {
+			delete(props, name)
+		}
+	}
+	return props, nil
+}
+
+// DatasetsWithUserProperty returns the datasets, regardless of type, whose user property name has the given value.
+// A filter argument may be passed to select a dataset with the matching name and its descendants, or empty string
+// ("") may be used to select all datasets. The value of the property is also available in the Extra field.
+func DatasetsWithUserProperty(filter, name, value string) ([]*Dataset, error) {
+	return defaultClient.DatasetsWithUserProperty(filter, name, value)
+}
+
+// DatasetsWithUserPropertyContext is like DatasetsWithUserProperty but includes a context.
+func DatasetsWithUserPropertyContext(ctx context.Context, filter, name, value string) ([]*Dataset, error) {
+	return defaultClient.DatasetsWithUserPropertyContext(ctx, filter, name, value)
+}
+
+// DatasetsWithUserProperty returns the datasets, regardless of type, whose user property name has the given value.
+// A filter argument may be passed to select a dataset with the matching name and its descendants, or empty string
+// ("") may be used to select all datasets. The value of the property is also available in the Extra field.
+func (c *Client) DatasetsWithUserProperty(filter, name, value string) ([]*Dataset, error) {
+	return c.DatasetsWithUserPropertyContext(context.Background(), filter, name, value)
+}
+
+// DatasetsWithUserPropertyContext is like DatasetsWithUserProperty but includes a context.
+func (c *Client) DatasetsWithUserPropertyContext(ctx context.Context, filter, name, value string) ([]*Dataset, error) {
+	if err := ValidateUserProperty(name); err != nil {
+		return nil, err
+	}
+	datasets, err := c.listByType(ctx, "all", filter, []string{name})
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []*Dataset
+	for _, ds := range datasets {
+		if ds.Extra[name] == value {
+			matching = append(matching, ds)
+		}
+	}
+	return matching, nil
+}
@@ -0,0 +1,29 @@
+package zfs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateUserProperty(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"com.example:owner":        true,
+		"com.example:backup_2-x.y": true,
+		"a:b:c":                    true,
+		"owner":                    false,
+		":owner":                   false,
+		"com.Example:owner":        false,
+		"com.example:own er":       false,
+		"-com.example:owner":       false,
+		"com.example:" + strings.Repeat("x", 256): false,
+	} {
+		err := ValidateUserProperty(name)
+		if valid && err != nil {
+			t.Errorf("%q: unexpected error: %v", name, err)
+		}
+		if !valid && !errors.Is(err, ErrInvalidProperty) {
+			t.Errorf("%q: wanted ErrInvalidProperty, got %v", name, err)
+		}
+	}
+}
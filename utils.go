@@ -6,13 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os/exec"
 	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync/atomic"
-	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,6 +24,13 @@ type Runner struct {
 	// Grace specifies the time waited after signaling the running process with SIGTERM before it is forcefully
 	// killed with SIGKILL.
 	Grace time.Duration
+
+	// Executor runs the commands. If nil, commands are run as local processes by a LocalExecutor using Grace.
+	Executor Executor
+
+	// Prefix is prepended to the command line of every command, to run them through a privilege escalation tool
+	// such as []string{"sudo", "-n"}, []string{"doas"} or []string{"pfexec"}.
+	Prefix []string
 }
 
 var defaultRunner atomic.Value
@@ -42,53 +47,63 @@ func SetRunner(runner *Runner) {
 	defaultRunner.Store(runner)
 }
 
+// Exec runs cmd with the runner's Executor, stopping it after Timeout or once ctx is done.
+func (r *Runner) Exec(ctx context.Context, cmd *Cmd) error {
+	if r.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	executor := r.Executor
+	if executor == nil {
+		executor = &LocalExecutor{Grace: r.Grace}
+	}
+	return executor.Exec(ctx, cmd)
+}
+
 type command struct {
 	Command string
 	Stdin   io.Reader
 	Stdout  io.Writer
+
+	// client provides the Runner and Logger, the default ones are used if nil.
+	client *Client
 }
 
 func (c *command) Run(arg ...string) ([][]string, error) {
-	var cmd *exec.Cmd
-	if Default().Timeout == 0 {
-		cmd = exec.Command(c.Command, arg...)
-	} else {
-		ctx, cancel := context.WithTimeout(context.Background(), Default().Timeout)
-		defer cancel()
-
-		cmd = exec.CommandContext(ctx, c.Command, arg...)
-		cmd.Cancel = func() error {
-			return cmd.Process.Signal(syscall.SIGTERM)
-		}
-		cmd.WaitDelay = Default().Grace
-	}
+	return c.RunContext(context.Background(), arg...)
+}
 
+// RunContext runs the command with the client's Runner, stopping it when ctx is done.
+func (c *command) RunContext(ctx context.Context, arg ...string) ([][]string, error) {
 	var stdout, stderr bytes.Buffer
 
+	runner := c.client.runner()
+	cmd := &Cmd{
+		Path:   c.Command,
+		Args:   arg,
+		Stdin:  c.Stdin,
+		Stdout: c.Stdout,
+		Stderr: &stderr,
+	}
+	if len(runner.Prefix) > 0 {
+		cmd.Path = runner.Prefix[0]
+		cmd.Args = append(append(append([]string{}, runner.Prefix[1:]...), c.Command), arg...)
+	}
 	if c.Stdout == nil {
 		cmd.Stdout = &stdout
-	} else {
-		cmd.Stdout = c.Stdout
-	}
-
-	if c.Stdin != nil {
-		cmd.Stdin = c.Stdin
 	}
-	cmd.Stderr = &stderr
 
 	id := uuid.New().String()
-	joinedArgs := cmd.Path
-	if len(cmd.Args) > 1 {
-		joinedArgs = strings.Join(append([]string{cmd.Path}, cmd.Args[1:]...), " ")
-	}
-
-	logger.Log([]string{"ID:" + id, "START", joinedArgs})
-	if err := cmd.Run(); err != nil {
-		return nil, &Error{
-			Err:    err,
-			Debug:  joinedArgs,
-			Stderr: stderr.String(),
+	logger := c.client.logger()
+	logger.Log([]string{"ID:" + id, "START", strings.Join(cmd.Argv(), " ")})
+	if err := runner.Exec(ctx, cmd); err != nil {
+		var prefix string
+		if len(runner.Prefix) > 0 {
+			prefix = runner.Prefix[0]
 		}
+		return nil, newError(err, strings.Join(cmd.Argv(), " "), stderr.String(), prefix)
 	}
 	logger.Log([]string{"ID:" + id, "FINISH"})
 
@@ -110,6 +125,36 @@ func (c *command) Run(arg ...string) ([][]string, error) {
 	return output, nil
 }
 
+// Messages printed by sudo, doas and pfexec when they refuse to run a command.
+var escalationMessages = []string{
+	"password",
+	"terminal is required",
+	"tty",
+	"sudoers",
+	"not allowed",
+	"not permitted",
+	"authentication",
+	"authorization",
+	"permission denied",
+}
+
+// escalationFailed reports whether stderr shows that the privilege escalation tool at path refused to run the command.
+func escalationFailed(path, stderr string) bool {
+	tool := path[strings.LastIndex(path, "/")+1:] + ":"
+	for _, line := range strings.Split(stderr, "\n") {
+		if !strings.HasPrefix(line, tool) {
+			continue
+		}
+		line = strings.ToLower(line)
+		for _, msg := range escalationMessages {
+			if strings.Contains(line, msg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func setString(field *string, value string) {
 	v := ""
 	if value != "-" {
@@ -124,19 +169,30 @@ func setUint(field *uint64, value string) error {
 		var err error
 		v, err = strconv.ParseUint(value, 10, 64)
 		if err != nil {
-			return err
+			// Tolerate sizes printed in human readable form, when -p is not honored.
+			size, serr := ParseSize(value)
+			if serr != nil {
+				return err
+			}
+			v = uint64(size)
 		}
 	}
 	*field = v
 	return nil
 }
 
-func (d *Dataset) parseLine(line []string) error {
+func (d *Dataset) parseLine(line []string, extra []string) error {
 	var err error
 
-	if len(line) != len(dsPropList) {
+	if len(line) != len(dsPropList)+len(extra) {
 		return errors.New("output does not match what is expected on this platform")
 	}
+	if len(extra) > 0 {
+		d.Extra = make(map[string]string, len(extra))
+		for i, prop := range extra {
+			d.Extra[prop] = line[len(dsPropList)+i]
+		}
+	}
 	setString(&d.Name, line[0])
 	setString(&d.Origin, line[1])
 
@@ -321,33 +377,17 @@ func parseInodeChanges(lines [][]string) ([]*InodeChange, error) {
 	return changes, nil
 }
 
-func listByType(t, filter string) ([]*Dataset, error) {
-	args := []string{"list", "-rHp", "-t", t, "-o", dsPropListOptions}
+func (c *Client) listByType(ctx context.Context, t, filter string, extra []string) ([]*Dataset, error) {
+	args := []string{"-r", "-t", t}
 
 	if filter != "" {
 		args = append(args, filter)
 	}
-	out, err := zfsOutput(args...)
+	out, err := c.listOutput(ctx, extra, args...)
 	if err != nil {
 		return nil, err
 	}
-
-	var datasets []*Dataset
-
-	name := ""
-	var ds *Dataset
-	for _, line := range out {
-		if name != line[0] {
-			name = line[0]
-			ds = &Dataset{Name: name}
-			datasets = append(datasets, ds)
-		}
-		if err := ds.parseLine(line); err != nil {
-			return nil, err
-		}
-	}
-
-	return datasets, nil
+	return c.datasets(out, extra)
 }
 
 func propsSlice(properties map[string]string) []string {
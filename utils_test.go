@@ -1,10 +1,14 @@
 package zfs
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os/exec"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseLine(t *testing.T) {
@@ -69,3 +73,116 @@ func TestCommandError(t *testing.T) {
 		})
 	}
 }
+
+func TestCommandContext(t *testing.T) {
+	cmd := &command{Command: "sleep"}
+	if _, err := exec.LookPath(cmd.Command); err != nil {
+		t.Skip(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := cmd.RunContext(ctx, "10")
+	if err == nil {
+		t.Fatal("command.RunContext: wanted error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("command.RunContext: process was not stopped on cancellation, took %v", elapsed)
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("command.RunContext (error): wanted *Error, got %T (%[1]v)", err)
+	}
+}
+
+type execFunc func(ctx context.Context, cmd *Cmd) error
+
+func (f execFunc) Exec(ctx context.Context, cmd *Cmd) error {
+	return f(ctx, cmd)
+}
+
+func TestCommandPrefix(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		prefix     []string
+		stderr     string
+		escalation bool
+	}{
+		{name: "SudoPassword", prefix: []string{"sudo", "-n"}, stderr: "sudo: a password is required\n", escalation: true},
+		{name: "SudoNotInSudoers", prefix: []string{"/usr/bin/sudo"}, stderr: "alice is not in the sudoers file.\nsudo: alice is not in the sudoers file.  This incident will be reported.\n", escalation: true},
+		{name: "Doas", prefix: []string{"doas", "-n"}, stderr: "doas: Operation not permitted\n", escalation: true},
+		{name: "ZFSError", prefix: []string{"sudo", "-n"}, stderr: "cannot open 'tank/x': permission denied\n"},
+		{name: "NoPrefix", stderr: "sudo: a password is required\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var argv []string
+			client := &Client{Runner: &Runner{
+				Prefix: tt.prefix,
+				Executor: execFunc(func(ctx context.Context, cmd *Cmd) error {
+					argv = cmd.Argv()
+					_, _ = cmd.Stderr.Write([]byte(tt.stderr))
+					return errors.New("exit status 1")
+				}),
+			}}
+
+			err := client.zfs(context.Background(), "list", "tank/x")
+			want := append(append([]string{}, tt.prefix...), "zfs", "list", "tank/x")
+			if !reflect.DeepEqual(argv, want) {
+				t.Fatalf("wanted argv %q, got %q", want, argv)
+			}
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("wanted *Error, got %T (%[1]v)", err)
+			}
+			if e.Debug != strings.Join(want, " ") {
+				t.Fatalf("wanted Debug %q, got %q", strings.Join(want, " "), e.Debug)
+			}
+			if got := errors.Is(err, ErrPrivilegeEscalation); got != tt.escalation {
+				t.Fatalf("errors.Is(err, ErrPrivilegeEscalation): wanted %v, got %v", tt.escalation, got)
+			}
+		})
+	}
+}
+
+func TestDestroySnapshotsBatches(t *testing.T) {
+	names := make([]string, 3000)
+	for i := range names {
+		names[i] = fmt.Sprintf("autosnap_2026-10-16_12:00:00_%06d", i)
+	}
+	var destroyed []string
+	client := &Client{Runner: &Runner{
+		Executor: execFunc(func(ctx context.Context, cmd *Cmd) error {
+			arg := cmd.Args[len(cmd.Args)-1]
+			if len(arg) > destroyBatchBytes {
+				t.Fatalf("argument of %d bytes exceeds the batch limit", len(arg))
+			}
+			if !strings.HasPrefix(arg, "tank/fs@") || len(strings.Split(arg, ",")) > destroyBatchCount {
+				t.Fatalf("unexpected argument: %.100s", arg)
+			}
+			destroyed = append(destroyed, strings.Split(strings.TrimPrefix(arg, "tank/fs@"), ",")...)
+			return nil
+		}),
+	}}
+
+	if err := client.DestroySnapshots("tank/fs", names, DestroyDefault); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(destroyed, names) {
+		t.Fatalf("wanted all %d snapshots destroyed in order, got %d", len(names), len(destroyed))
+	}
+	if batches := snapshotBatches("tank/fs", names); len(batches) < 2 {
+		t.Fatalf("wanted several batches, got %d", len(batches))
+	}
+	short := make([]string, 2500)
+	for i := range short {
+		short[i] = fmt.Sprint(i)
+	}
+	if batches := snapshotBatches("tank/fs", short); len(batches) != 3 || len(batches[0]) != destroyBatchCount {
+		t.Fatalf("wanted batches of at most %d snapshots, got %d batches", destroyBatchCount, len(batches))
+	}
+	if batches := snapshotBatches("tank/fs", names[:3]); len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("wanted a single batch, got %v", batches)
+	}
+}
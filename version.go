@@ -0,0 +1,290 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is matched, using errors.Is, by errors returned when an operation requires a feature the installed
+// ZFS version lacks.
+var ErrUnsupported = errors.New("not supported by the installed ZFS version")
+
+// Feature is an optional capability of the ZFS tools, available from a given OpenZFS version.
+type Feature int
+
+// Features which depend on the OpenZFS version.
+const (
+	// FeatureBookmarks is support for bookmarks, added in ZFS on Linux 0.6.4.
+	FeatureBookmarks Feature = iota
+	// FeatureCompressedSend is `zfs send -c`, sending compressed blocks as is, added in 0.7.
+	FeatureCompressedSend
+	// FeatureEncryption is native encryption, added in 0.8.
+	FeatureEncryption
+	// FeatureRawSend is `zfs send -w`, sending encrypted datasets as is, added in 0.8.
+	FeatureRawSend
+	// FeatureRedaction is redacted send streams and redaction bookmarks, added in 2.0.
+	FeatureRedaction
+	// FeatureZpoolWait is `zpool wait`, added in 2.0.
+	FeatureZpoolWait
+	// FeatureSendBackup is `zfs send -b`, sending received property values, added in 2.0.
+	FeatureSendBackup
+	// FeatureSendHolds is `zfs send -h`, including snapshot holds in the stream, added in 2.0.
+	FeatureSendHolds
+	// FeatureSendSaved is `zfs send -S`, sending the saved state of a partial receive, added in 2.0.
+	FeatureSendSaved
+	// FeatureBookmarkCopy is creating a bookmark from another bookmark, added in 2.0.
+	FeatureBookmarkCopy
+	// FeatureJSON is JSON output (-j) of the zfs and zpool commands, added in 2.3.
+	FeatureJSON
+)
+
+var features = []struct {
+	name                string
+	major, minor, patch int
+}{
+	FeatureBookmarks:      {"bookmarks", 0, 6, 4},
+	FeatureCompressedSend: {"compressed send", 0, 7, 0},
+	FeatureEncryption:     {"encryption", 0, 8, 0},
+	FeatureRawSend:        {"raw send", 0, 8, 0},
+	FeatureRedaction:      {"redacted send", 2, 0, 0},
+	FeatureZpoolWait:      {"zpool wait", 2, 0, 0},
+	FeatureSendBackup:     {"backup send", 2, 0, 0},
+	FeatureSendHolds:      {"sending holds", 2, 0, 0},
+	FeatureSendSaved:      {"saved send", 2, 0, 0},
+	FeatureBookmarkCopy:   {"bookmark copies", 2, 0, 0},
+	FeatureJSON:           {"JSON output", 2, 3, 0},
+}
+
+func (f Feature) String() string {
+	if f < 0 || int(f) >= len(features) {
+		return "feature " + strconv.Itoa(int(f))
+	}
+	return features[f].name
+}
+
+// legacyFeatures are the features of the ZFS implementations predating `zfs version`, which are known per platform
+// rather than per version.
+var legacyFeatures = map[string][]Feature{
+	"illumos": {FeatureBookmarks, FeatureCompressedSend, FeatureEncryption, FeatureRawSend},
+	"freebsd": {FeatureBookmarks, FeatureCompressedSend},
+	"solaris": {},
+}
+
+// VersionInfo is the version of the ZFS userland tools and kernel module, as printed by `zfs version`.
+type VersionInfo struct {
+	// Userland and Kernel are the full versions of the tools and of the kernel module, such as "2.1.5-1ubuntu6".
+	// Kernel is empty if the module version is unknown. Both are empty for ZFS implementations predating
+	// `zfs version`, which are identified by Platform instead.
+	Userland string
+	Kernel   string
+
+	// Platform is the operating system of a ZFS implementation predating `zfs version`, named like runtime.GOOS,
+	// such as "solaris", "illumos", "freebsd" for FreeBSD before 13, or "linux" for ZFS on Linux before 0.8. It is
+	// detected with `uname -o`, and empty for OpenZFS.
+	Platform string
+
+	// Major, Minor and Patch are the numeric parts of the older of Userland and Kernel, which determines the
+	// supported features.
+	Major int
+	Minor int
+	Patch int
+}
+
+func (v *VersionInfo) String() string {
+	if v.Platform != "" {
+		return v.Platform
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is major.minor.patch or later.
+func (v *VersionInfo) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+// Supports reports whether feature f is available in v. Features of ZFS implementations predating `zfs version` are
+// only known for the platforms "solaris", "illumos" and "freebsd".
+func (v *VersionInfo) Supports(f Feature) bool {
+	if f < 0 || int(f) >= len(features) {
+		return false
+	}
+	if v.Platform != "" {
+		for _, lf := range legacyFeatures[v.Platform] {
+			if lf == f {
+				return true
+			}
+		}
+		return false
+	}
+	req := features[f]
+	return v.AtLeast(req.major, req.minor, req.patch)
+}
+
+var versionNumberRegex = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseVersionNumber parses the leading major.minor[.patch] of a version.
+func parseVersionNumber(s string) ([3]int, bool) {
+	var n [3]int
+	m := versionNumberRegex.FindStringSubmatch(s)
+	if m == nil {
+		return n, false
+	}
+	for i := range n {
+		n[i], _ = strconv.Atoi(m[i+1])
+	}
+	return n, true
+}
+
+// parseVersion parses the output of `zfs version`, such as:
+//
+//	zfs-2.1.5-1ubuntu6~22.04.1
+//	zfs-kmod-2.1.5-1ubuntu6~22.04.1
+func parseVersion(out [][]string) (*VersionInfo, error) {
+	v := &VersionInfo{}
+	for _, line := range out {
+		switch s := strings.TrimSpace(line[0]); {
+		case strings.HasPrefix(s, "zfs-kmod-"):
+			v.Kernel = strings.TrimPrefix(s, "zfs-kmod-")
+		case strings.HasPrefix(s, "zfs-"):
+			v.Userland = strings.TrimPrefix(s, "zfs-")
+		}
+	}
+
+	n, ok := parseVersionNumber(v.Userland)
+	if !ok {
+		return nil, fmt.Errorf("unexpected output of zfs version: %q", out)
+	}
+	if k, ok := parseVersionNumber(v.Kernel); ok && (k[0] < n[0] || k[0] == n[0] && (k[1] < n[1] || k[1] == n[1] && k[2] < n[2])) {
+		n = k
+	}
+	v.Major, v.Minor, v.Patch = n[0], n[1], n[2]
+	return v, nil
+}
+
+// Version returns the version of the installed ZFS, using `zfs version` or `zfs --version`. For ZFS implementations
+// lacking both, such as those of Solaris, illumos, FreeBSD before 13 and ZFS on Linux before 0.8, only the Platform
+// is reported.
+func Version() (*VersionInfo, error) {
+	return defaultClient.Version()
+}
+
+// VersionContext is like Version but includes a context.
+func VersionContext(ctx context.Context) (*VersionInfo, error) {
+	return defaultClient.VersionContext(ctx)
+}
+
+// Version returns the version of the installed ZFS, using `zfs version` or `zfs --version`. For ZFS implementations
+// lacking both, such as those of Solaris, illumos, FreeBSD before 13 and ZFS on Linux before 0.8, only the Platform
+// is reported.
+//
+// The outcome is cached by the Client for its Runner. A failure other than a zfs command lacking the version
+// subcommand, such as an unreachable host, is retried by the next call to Version, while the commands depending on
+// the version keep the cached failure and treat every feature as unknown rather than running the probe again.
+func (c *Client) Version() (*VersionInfo, error) {
+	return c.VersionContext(context.Background())
+}
+
+// VersionContext is like Version but includes a context.
+func (c *Client) VersionContext(ctx context.Context) (*VersionInfo, error) {
+	return c.detectVersion(ctx, true)
+}
+
+// detectVersion returns the version of the installed ZFS, cached per Runner. A cached failure which may be transient
+// is only retried if retry is true. Failures caused by ctx being done are not cached.
+func (c *Client) detectVersion(ctx context.Context, retry bool) (*VersionInfo, error) {
+	if c == nil {
+		c = defaultClient
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	runner := c.runner()
+	if c.versionRunner == runner && !(retry && c.versionTransient) {
+		return c.version, c.versionErr
+	}
+
+	v, transient, err := c.probeVersion(ctx)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	c.version, c.versionErr, c.versionTransient, c.versionRunner = v, err, transient, runner
+	return v, err
+}
+
+// probeVersion runs the commands detecting the version of the installed ZFS, and reports whether a failure may be
+// transient rather than caused by the output of the commands.
+func (c *Client) probeVersion(ctx context.Context) (*VersionInfo, bool, error) {
+	out, err := c.zfsOutput(ctx, "version")
+	if isUsageError(err) {
+		out, err = c.zfsOutput(ctx, "--version")
+	}
+	switch {
+	case err == nil:
+		v, err := parseVersion(out)
+		return v, false, err
+	case isUsageError(err):
+		v, err := c.legacyVersion(ctx)
+		return v, err != nil, err
+	default:
+		return nil, true, err
+	}
+}
+
+// legacyVersion identifies the platform of a ZFS implementation predating `zfs version`.
+func (c *Client) legacyVersion(ctx context.Context) (*VersionInfo, error) {
+	cmd := command{Command: "uname", client: c}
+	out, err := cmd.RunContext(ctx, "-o")
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 || len(out[0]) == 0 || strings.TrimSpace(out[0][0]) == "" {
+		return nil, errors.New("unexpected output of uname")
+	}
+	platform := strings.ToLower(strings.TrimSpace(out[0][0]))
+	return &VersionInfo{Platform: strings.TrimPrefix(platform, "gnu/")}, nil
+}
+
+// isUsageError reports whether err is the failure of a zfs or zpool command which rejected its arguments, as they do
+// with exit code 2 for unknown subcommands and options.
+func isUsageError(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.ExitCode == 2
+}
+
+// supports reports whether the installed ZFS is known to support f.
+func (c *Client) supports(ctx context.Context, f Feature) bool {
+	v, err := c.detectVersion(ctx, false)
+	return err == nil && v.Supports(f)
+}
+
+// require returns an error matching ErrUnsupported if the installed ZFS is known to lack f. When the version cannot be
+// detected, or the features of the platform are unknown, the command is attempted anyway, and fails on its own if f
+// is missing.
+func (c *Client) require(ctx context.Context, f Feature) error {
+	v, err := c.detectVersion(ctx, false)
+	if err != nil {
+		return ctx.Err()
+	}
+	if v.Platform != "" {
+		if _, known := legacyFeatures[v.Platform]; known && !v.Supports(f) {
+			return fmt.Errorf("%w: %s is not available on %s", ErrUnsupported, f, v.Platform)
+		}
+		return nil
+	}
+	if !v.Supports(f) {
+		req := features[f]
+		return fmt.Errorf("%w: %s requires OpenZFS %d.%d.%d, found %s", ErrUnsupported, f, req.major, req.minor, req.patch, v)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	for _, tt := range []struct {
+		out              string
+		userland, kernel string
+		version          string
+	}{
+		{"zfs-2.1.5-1ubuntu6~22.04.1\nzfs-kmod-2.1.5-1ubuntu6~22.04.1", "2.1.5-1ubuntu6~22.04.1", "2.1.5-1ubuntu6~22.04.1", "2.1.5"},
+		{"zfs-2.1.4-FreeBSD_g52bad4f23\nzfs-kmod-2.1.4-FreeBSD_g52bad4f23", "2.1.4-FreeBSD_g52bad4f23", "2.1.4-FreeBSD_g52bad4f23", "2.1.4"},
+		{"zfs-2.2.0-1\nzfs-kmod-0.8.3-1", "2.2.0-1", "0.8.3-1", "0.8.3"},
+		{"zfs-2.3.0-1", "2.3.0-1", "", "2.3.0"},
+	} {
+		var out [][]string
+		for _, line := range strings.Split(tt.out, "\n") {
+			out = append(out, []string{line})
+		}
+		v, err := parseVersion(out)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.out, err)
+		}
+		if v.Userland != tt.userland || v.Kernel != tt.kernel || v.String() != tt.version {
+			t.Fatalf("%q: unexpected version %+v", tt.out, v)
+		}
+	}
+
+	if _, err := parseVersion([][]string{{"unrecognized command 'version'"}}); err == nil {
+		t.Fatal("wanted an error for unexpected output")
+	}
+}
+
+func TestVersionFeatures(t *testing.T) {
+	v := &VersionInfo{Major: 2, Minor: 1, Patch: 5}
+	for f, want := range map[Feature]bool{
+		FeatureBookmarks:  true,
+		FeatureEncryption: true,
+		FeatureRawSend:    true,
+		FeatureRedaction:  true,
+		FeatureZpoolWait:  true,
+		FeatureJSON:       false,
+	} {
+		if got := v.Supports(f); got != want {
+			t.Errorf("%s: got %v, wanted %v", f, got, want)
+		}
+	}
+
+	calls := 0
+	client := &Client{Runner: &Runner{
+		Executor: execFunc(func(ctx context.Context, cmd *Cmd) error {
+			calls++
+			_, err := io.WriteString(cmd.Stdout, "zfs-0.7.13-1\nzfs-kmod-0.7.13-1\n")
+			return err
+		}),
+	}}
+	err := client.require(context.Background(), FeatureRawSend)
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("wanted ErrUnsupported, got %v", err)
+	}
+	if err := client.require(context.Background(), FeatureBookmarks); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("wanted the version to be detected once, got %d calls", calls)
+	}
+
+	unknown := &Client{Runner: &Runner{
+		Executor: execFunc(func(ctx context.Context, cmd *Cmd) error {
+			return errors.New("exit status 2")
+		}),
+	}}
+	if err := unknown.require(context.Background(), FeatureRawSend); err != nil {
+		t.Fatalf("wanted unknown versions to be attempted, got %v", err)
+	}
+}
+
+func TestVersionCache(t *testing.T) {
+	var calls int
+	var fail error
+	client := &Client{Runner: &Runner{
+		Executor: execFunc(func(ctx context.Context, cmd *Cmd) error {
+			calls++
+			if fail != nil {
+				_, _ = io.WriteString(cmd.Stderr, "ssh: connect to host storage1 port 22: Connection refused\n")
+				return fail
+			}
+			_, err := io.WriteString(cmd.Stdout, "zfs-2.1.5-1\nzfs-kmod-2.1.5-1\n")
+			return err
+		}),
+	}}
+
+	fail = exitError(255)
+	if _, err := client.Version(); err == nil {
+		t.Fatal("wanted an error while the host is unreachable")
+	}
+	for i := 0; i < 3; i++ {
+		if client.supports(context.Background(), FeatureJSON) {
+			t.Fatal("wanted features to be unknown after a failed detection")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("wanted the failed detection to be cached for commands, got %d calls", calls)
+	}
+	fail = nil
+	v, err := client.Version()
+	if err != nil {
+		t.Fatalf("wanted the version to be detected again, got %v", err)
+	}
+	if _, err := client.Version(); err != nil || v.String() != "2.1.5" || !client.supports(context.Background(), FeatureZpoolWait) || calls != 2 {
+		t.Fatalf("wanted the detected version to be cached, got %v after %d calls", err, calls)
+	}
+
+	var argvs []string
+	legacy := func(platform string) *Client {
+		argvs = nil
+		return &Client{Runner: &Runner{
+			Executor: execFunc(func(ctx context.Context, cmd *Cmd) error {
+				argvs = append(argvs, strings.Join(cmd.Argv(), " "))
+				if cmd.Path == "uname" {
+					_, err := io.WriteString(cmd.Stdout, platform+"\n")
+					return err
+				}
+				_, _ = io.WriteString(cmd.Stderr, "unrecognized command '"+cmd.Args[0]+"'\nusage: zfs command args ...\n")
+				return exitError(2)
+			}),
+		}}
+	}
+
+	illumos := legacy("illumos")
+	for i := 0; i < 2; i++ {
+		v, err := illumos.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Platform != "illumos" || v.Userland != "" || v.String() != "illumos" {
+			t.Fatalf("unexpected legacy version: %+v", v)
+		}
+	}
+	if want := []string{"zfs version", "zfs --version", "uname -o"}; !reflect.DeepEqual(argvs, want) {
+		t.Fatalf("wanted the legacy platform to be detected once with %q, got %q", want, argvs)
+	}
+	if err := illumos.require(context.Background(), FeatureRawSend); err != nil {
+		t.Fatal(err)
+	}
+	if err := illumos.require(context.Background(), FeatureZpoolWait); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("wanted ErrUnsupported on illumos, got %v", err)
+	}
+	if illumos.useJSON(context.Background()) {
+		t.Fatal("wanted no JSON output on illumos")
+	}
+
+	linux := legacy("GNU/Linux")
+	v, err = linux.Version()
+	if err != nil || v.Platform != "linux" {
+		t.Fatalf("unexpected legacy version: %+v, %v", v, err)
+	}
+	if err := linux.require(context.Background(), FeatureRawSend); err != nil {
+		t.Fatalf("wanted unknown features to be attempted, got %v", err)
+	}
+}
+
+func TestVersionFlag(t *testing.T) {
+	client := &Client{Runner: &Runner{
+		Executor: execFunc(func(ctx context.Context, cmd *Cmd) error {
+			if cmd.Args[0] != "--version" {
+				return exitError(2)
+			}
+			_, err := io.WriteString(cmd.Stdout, "zfs-0.8.6-1\nzfs-kmod-0.8.6-1\n")
+			return err
+		}),
+	}}
+	v, err := client.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != "0.8.6" || !v.Supports(FeatureEncryption) {
+		t.Fatalf("unexpected version: %+v", v)
+	}
+}
@@ -2,6 +2,7 @@
 package zfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -14,9 +15,10 @@ const (
 	DatasetFilesystem = "filesystem"
 	DatasetSnapshot   = "snapshot"
 	DatasetVolume     = "volume"
+	DatasetBookmark   = "bookmark"
 )
 
-// Dataset is a ZFS dataset.  A dataset could be a clone, filesystem, snapshot, or volume.
+// Dataset is a ZFS dataset.  A dataset could be a clone, filesystem, snapshot, volume, or bookmark.
 // The Type struct member can be used to determine a dataset's type.
 //
 // The field definitions can be found in the ZFS manual:
@@ -35,6 +37,12 @@ type Dataset struct {
 	Usedbydataset uint64
 	Quota         uint64
 	Referenced    uint64
+
+	// Extra holds the values of the additional properties requested when the dataset was listed, keyed by property
+	// name as requested.
+	Extra map[string]string
+
+	client *Client
 }
 
 // InodeType is the type of inode as reported by Diff.
@@ -106,53 +114,131 @@ func SetLogger(l Logger) {
 	}
 }
 
-// zfs is a helper function to wrap typical calls to zfs that ignores stdout.
-func zfs(arg ...string) error {
-	_, err := zfsOutput(arg...)
-	return err
+// Datasets returns a slice of ZFS datasets, regardless of type.
+// A filter argument may be passed to select a dataset with the matching name, or empty string ("") may be used to select all datasets.
+// Extra property names may be given to populate the Extra field of each dataset from the same zfs command.
+func Datasets(filter string, extra ...string) ([]*Dataset, error) {
+	return defaultClient.Datasets(filter, extra...)
 }
 
-// zfs is a helper function to wrap typical calls to zfs.
-func zfsOutput(arg ...string) ([][]string, error) {
-	c := command{Command: "zfs"}
-	return c.Run(arg...)
+// DatasetsContext is like Datasets but includes a context.
+func DatasetsContext(ctx context.Context, filter string, extra ...string) ([]*Dataset, error) {
+	return defaultClient.DatasetsContext(ctx, filter, extra...)
 }
 
 // Datasets returns a slice of ZFS datasets, regardless of type.
 // A filter argument may be passed to select a dataset with the matching name, or empty string ("") may be used to select all datasets.
-func Datasets(filter string) ([]*Dataset, error) {
-	return listByType("all", filter)
+// Extra property names may be given to populate the Extra field of each dataset from the same zfs command.
+func (c *Client) Datasets(filter string, extra ...string) ([]*Dataset, error) {
+	return c.DatasetsContext(context.Background(), filter, extra...)
+}
+
+// DatasetsContext is like Datasets but includes a context.
+func (c *Client) DatasetsContext(ctx context.Context, filter string, extra ...string) ([]*Dataset, error) {
+	return c.listByType(ctx, "all", filter, extra)
 }
 
 // Snapshots returns a slice of ZFS snapshots.
 // A filter argument may be passed to select a snapshot with the matching name, or empty string ("") may be used to select all snapshots.
-func Snapshots(filter string) ([]*Dataset, error) {
-	return listByType(DatasetSnapshot, filter)
+// Extra property names may be given to populate the Extra field of each dataset from the same zfs command.
+func Snapshots(filter string, extra ...string) ([]*Dataset, error) {
+	return defaultClient.Snapshots(filter, extra...)
+}
+
+// SnapshotsContext is like Snapshots but includes a context.
+func SnapshotsContext(ctx context.Context, filter string, extra ...string) ([]*Dataset, error) {
+	return defaultClient.SnapshotsContext(ctx, filter, extra...)
+}
+
+// Snapshots returns a slice of ZFS snapshots.
+// A filter argument may be passed to select a snapshot with the matching name, or empty string ("") may be used to select all snapshots.
+// Extra property names may be given to populate the Extra field of each dataset from the same zfs command.
+func (c *Client) Snapshots(filter string, extra ...string) ([]*Dataset, error) {
+	return c.SnapshotsContext(context.Background(), filter, extra...)
+}
+
+// SnapshotsContext is like Snapshots but includes a context.
+func (c *Client) SnapshotsContext(ctx context.Context, filter string, extra ...string) ([]*Dataset, error) {
+	return c.listByType(ctx, DatasetSnapshot, filter, extra)
+}
+
+// Filesystems returns a slice of ZFS filesystems.
+// A filter argument may be passed to select a filesystem with the matching name, or empty string ("") may be used to select all filesystems.
+// Extra property names may be given to populate the Extra field of each dataset from the same zfs command.
+func Filesystems(filter string, extra ...string) ([]*Dataset, error) {
+	return defaultClient.Filesystems(filter, extra...)
+}
+
+// FilesystemsContext is like Filesystems but includes a context.
+func FilesystemsContext(ctx context.Context, filter string, extra ...string) ([]*Dataset, error) {
+	return defaultClient.FilesystemsContext(ctx, filter, extra...)
 }
 
 // Filesystems returns a slice of ZFS filesystems.
 // A filter argument may be passed to select a filesystem with the matching name, or empty string ("") may be used to select all filesystems.
-func Filesystems(filter string) ([]*Dataset, error) {
-	return listByType(DatasetFilesystem, filter)
+// Extra property names may be given to populate the Extra field of each dataset from the same zfs command.
+func (c *Client) Filesystems(filter string, extra ...string) ([]*Dataset, error) {
+	return c.FilesystemsContext(context.Background(), filter, extra...)
+}
+
+// FilesystemsContext is like Filesystems but includes a context.
+func (c *Client) FilesystemsContext(ctx context.Context, filter string, extra ...string) ([]*Dataset, error) {
+	return c.listByType(ctx, DatasetFilesystem, filter, extra)
 }
 
 // Volumes returns a slice of ZFS volumes.
 // A filter argument may be passed to select a volume with the matching name, or empty string ("") may be used to select all volumes.
-func Volumes(filter string) ([]*Dataset, error) {
-	return listByType(DatasetVolume, filter)
+// Extra property names may be given to populate the Extra field of each dataset from the same zfs command.
+func Volumes(filter string, extra ...string) ([]*Dataset, error) {
+	return defaultClient.Volumes(filter, extra...)
+}
+
+// VolumesContext is like Volumes but includes a context.
+func VolumesContext(ctx context.Context, filter string, extra ...string) ([]*Dataset, error) {
+	return defaultClient.VolumesContext(ctx, filter, extra...)
+}
+
+// Volumes returns a slice of ZFS volumes.
+// A filter argument may be passed to select a volume with the matching name, or empty string ("") may be used to select all volumes.
+// Extra property names may be given to populate the Extra field of each dataset from the same zfs command.
+func (c *Client) Volumes(filter string, extra ...string) ([]*Dataset, error) {
+	return c.VolumesContext(context.Background(), filter, extra...)
+}
+
+// VolumesContext is like Volumes but includes a context.
+func (c *Client) VolumesContext(ctx context.Context, filter string, extra ...string) ([]*Dataset, error) {
+	return c.listByType(ctx, DatasetVolume, filter, extra)
 }
 
 // GetDataset retrieves a single ZFS dataset by name.
 // This dataset could be any valid ZFS dataset type, such as a clone, filesystem, snapshot, or volume.
-func GetDataset(name string) (*Dataset, error) {
-	out, err := zfsOutput("list", "-Hp", "-o", dsPropListOptions, name)
+// Extra property names may be given to populate the Extra field of the dataset.
+func GetDataset(name string, extra ...string) (*Dataset, error) {
+	return defaultClient.GetDataset(name, extra...)
+}
+
+// GetDatasetContext is like GetDataset but includes a context.
+func GetDatasetContext(ctx context.Context, name string, extra ...string) (*Dataset, error) {
+	return defaultClient.GetDatasetContext(ctx, name, extra...)
+}
+
+// GetDataset retrieves a single ZFS dataset by name.
+// This dataset could be any valid ZFS dataset type, such as a clone, filesystem, snapshot, or volume.
+// Extra property names may be given to populate the Extra field of the dataset.
+func (c *Client) GetDataset(name string, extra ...string) (*Dataset, error) {
+	return c.GetDatasetContext(context.Background(), name, extra...)
+}
+
+// GetDatasetContext is like GetDataset but includes a context.
+func (c *Client) GetDatasetContext(ctx context.Context, name string, extra ...string) (*Dataset, error) {
+	out, err := c.listOutput(ctx, extra, name)
 	if err != nil {
 		return nil, err
 	}
 
-	ds := &Dataset{Name: name}
+	ds := &Dataset{Name: name, client: c}
 	for _, line := range out {
-		if err := ds.parseLine(line); err != nil {
+		if err := ds.parseLine(line, extra); err != nil {
 			return nil, err
 		}
 	}
@@ -163,6 +249,11 @@ func GetDataset(name string) (*Dataset, error) {
 // Clone clones a ZFS snapshot and returns a clone dataset.
 // An error will be returned if the input dataset is not of snapshot type.
 func (d *Dataset) Clone(dest string, properties map[string]string) (*Dataset, error) {
+	return d.CloneContext(context.Background(), dest, properties)
+}
+
+// CloneContext is like Clone but includes a context.
+func (d *Dataset) CloneContext(ctx context.Context, dest string, properties map[string]string) (*Dataset, error) {
 	if d.Type != DatasetSnapshot {
 		return nil, errors.New("can only clone snapshots")
 	}
@@ -173,14 +264,19 @@ func (d *Dataset) Clone(dest string, properties map[string]string) (*Dataset, er
 		args = append(args, propsSlice(properties)...)
 	}
 	args = append(args, []string{d.Name, dest}...)
-	if err := zfs(args...); err != nil {
+	if err := d.client.zfs(ctx, args...); err != nil {
 		return nil, err
 	}
-	return GetDataset(dest)
+	return d.client.GetDatasetContext(ctx, dest)
 }
 
 // Unmount unmounts currently mounted ZFS file systems.
 func (d *Dataset) Unmount(force bool) (*Dataset, error) {
+	return d.UnmountContext(context.Background(), force)
+}
+
+// UnmountContext is like Unmount but includes a context.
+func (d *Dataset) UnmountContext(ctx context.Context, force bool) (*Dataset, error) {
 	if d.Type == DatasetSnapshot {
 		return nil, errors.New("cannot unmount snapshots")
 	}
@@ -190,14 +286,19 @@ func (d *Dataset) Unmount(force bool) (*Dataset, error) {
 		args = append(args, "-f")
 	}
 	args = append(args, d.Name)
-	if err := zfs(args...); err != nil {
+	if err := d.client.zfs(ctx, args...); err != nil {
 		return nil, err
 	}
-	return GetDataset(d.Name)
+	return d.client.GetDatasetContext(ctx, d.Name)
 }
 
 // Mount mounts ZFS file systems.
 func (d *Dataset) Mount(overlay bool, options []string) (*Dataset, error) {
+	return d.MountContext(context.Background(), overlay, options)
+}
+
+// MountContext is like Mount but includes a context.
+func (d *Dataset) MountContext(ctx context.Context, overlay bool, options []string) (*Dataset, error) {
 	if d.Type == DatasetSnapshot {
 		return nil, errors.New("cannot mount snapshots")
 	}
@@ -211,50 +312,101 @@ func (d *Dataset) Mount(overlay bool, options []string) (*Dataset, error) {
 		args = append(args, strings.Join(options, ","))
 	}
 	args = append(args, d.Name)
-	if err := zfs(args...); err != nil {
+	if err := d.client.zfs(ctx, args...); err != nil {
 		return nil, err
 	}
-	return GetDataset(d.Name)
+	return d.client.GetDatasetContext(ctx, d.Name)
 }
 
 // ReceiveSnapshot receives a ZFS stream from the input io.Reader.
 // A new snapshot is created with the specified name, and streams the input data into the newly-created snapshot.
+// Use Receive for more options.
 func ReceiveSnapshot(input io.Reader, name string) (*Dataset, error) {
-	c := command{Command: "zfs", Stdin: input}
-	if _, err := c.Run("receive", name); err != nil {
+	return defaultClient.ReceiveSnapshot(input, name)
+}
+
+// ReceiveSnapshotContext is like ReceiveSnapshot but includes a context.
+func ReceiveSnapshotContext(ctx context.Context, input io.Reader, name string) (*Dataset, error) {
+	return defaultClient.ReceiveSnapshotContext(ctx, input, name)
+}
+
+// ReceiveSnapshot receives a ZFS stream from the input io.Reader.
+// A new snapshot is created with the specified name, and streams the input data into the newly-created snapshot.
+// Use Receive for more options.
+func (c *Client) ReceiveSnapshot(input io.Reader, name string) (*Dataset, error) {
+	return c.ReceiveSnapshotContext(context.Background(), input, name)
+}
+
+// ReceiveSnapshotContext is like ReceiveSnapshot but includes a context.
+func (c *Client) ReceiveSnapshotContext(ctx context.Context, input io.Reader, name string) (*Dataset, error) {
+	cmd := command{Command: c.zfsPath(), Stdin: input, client: c}
+	if _, err := cmd.RunContext(ctx, "receive", name); err != nil {
 		return nil, err
 	}
-	return GetDataset(name)
+	return c.GetDatasetContext(ctx, name)
 }
 
 // SendSnapshot sends a ZFS stream of a snapshot to the input io.Writer.
+// Use Send for more options.
 // An error will be returned if the input dataset is not of snapshot type.
 func (d *Dataset) SendSnapshot(output io.Writer) error {
+	return d.SendSnapshotContext(context.Background(), output)
+}
+
+// SendSnapshotContext is like SendSnapshot but includes a context.
+func (d *Dataset) SendSnapshotContext(ctx context.Context, output io.Writer) error {
 	if d.Type != DatasetSnapshot {
 		return errors.New("can only send snapshots")
 	}
 
-	c := command{Command: "zfs", Stdout: output}
-	_, err := c.Run("send", d.Name)
+	c := command{Command: d.client.zfsPath(), Stdout: output, client: d.client}
+	_, err := c.RunContext(ctx, "send", d.Name)
 	return err
 }
 
 // IncrementalSend sends a ZFS stream of a snapshot to the input io.Writer using the baseSnapshot as the starting point.
+// The base may also be a bookmark of an earlier snapshot, which allows that snapshot to be destroyed on the sending
+// side while keeping incremental sends possible.
 // An error will be returned if the input dataset is not of snapshot type.
 func (d *Dataset) IncrementalSend(baseSnapshot *Dataset, output io.Writer) error {
-	if d.Type != DatasetSnapshot || baseSnapshot.Type != DatasetSnapshot {
+	return d.IncrementalSendContext(context.Background(), baseSnapshot, output)
+}
+
+// IncrementalSendContext is like IncrementalSend but includes a context.
+func (d *Dataset) IncrementalSendContext(ctx context.Context, baseSnapshot *Dataset, output io.Writer) error {
+	if d.Type != DatasetSnapshot || (baseSnapshot.Type != DatasetSnapshot && baseSnapshot.Type != DatasetBookmark) {
 		return errors.New("can only send snapshots")
 	}
-	c := command{Command: "zfs", Stdout: output}
-	_, err := c.Run("send", "-i", baseSnapshot.Name, d.Name)
+	c := command{Command: d.client.zfsPath(), Stdout: output, client: d.client}
+	_, err := c.RunContext(ctx, "send", "-i", baseSnapshot.Name, d.Name)
 	return err
 }
 
 // CreateVolume creates a new ZFS volume with the specified name, size, and properties.
+// The size is in bytes: ParseSize converts sizes such as "10G" to a Size, passed as uint64(size).
 //
 // A full list of available ZFS properties may be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
 func CreateVolume(name string, size uint64, properties map[string]string) (*Dataset, error) {
+	return defaultClient.CreateVolume(name, size, properties)
+}
+
+// CreateVolumeContext is like CreateVolume but includes a context.
+func CreateVolumeContext(ctx context.Context, name string, size uint64, properties map[string]string) (*Dataset, error) {
+	return defaultClient.CreateVolumeContext(ctx, name, size, properties)
+}
+
+// CreateVolume creates a new ZFS volume with the specified name, size, and properties.
+// The size is in bytes: ParseSize converts sizes such as "10G" to a Size, passed as uint64(size).
+//
+// A full list of available ZFS properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
+func (c *Client) CreateVolume(name string, size uint64, properties map[string]string) (*Dataset, error) {
+	return c.CreateVolumeContext(context.Background(), name, size, properties)
+}
+
+// CreateVolumeContext is like CreateVolume but includes a context.
+func (c *Client) CreateVolumeContext(ctx context.Context, name string, size uint64, properties map[string]string) (*Dataset, error) {
 	args := make([]string, 4, 5)
 	args[0] = "create"
 	args[1] = "-p"
@@ -264,17 +416,33 @@ func CreateVolume(name string, size uint64, properties map[string]string) (*Data
 		args = append(args, propsSlice(properties)...)
 	}
 	args = append(args, name)
-	if err := zfs(args...); err != nil {
+	if err := c.zfs(ctx, args...); err != nil {
 		return nil, err
 	}
-	return GetDataset(name)
+	return c.GetDatasetContext(ctx, name)
 }
 
 // Destroy destroys a ZFS dataset.
 // If the destroy bit flag is set, any descendents of the dataset will be recursively destroyed, including snapshots.
 // If the deferred bit flag is set, the snapshot is marked for deferred deletion.
+// Destroying a snapshot with user holds fails with an error matching ErrHeld, unless deletion is deferred: the
+// snapshot is then destroyed once the last hold is released.
 func (d *Dataset) Destroy(flags DestroyFlag) error {
-	args := make([]string, 1, 3)
+	return d.DestroyContext(context.Background(), flags)
+}
+
+// DestroyContext is like Destroy but includes a context.
+func (d *Dataset) DestroyContext(ctx context.Context, flags DestroyFlag) error {
+	err := d.client.zfs(ctx, append(destroyArgs(flags), d.Name)...)
+	if d.Type == DatasetSnapshot {
+		d.client.classifyHeld(ctx, err, d.Name)
+	}
+	return err
+}
+
+// destroyArgs returns the arguments of `zfs destroy` for flags.
+func destroyArgs(flags DestroyFlag) []string {
+	args := make([]string, 1, 5)
 	args[0] = "destroy"
 	if flags&DestroyRecursive != 0 {
 		args = append(args, "-r")
@@ -291,10 +459,94 @@ func (d *Dataset) Destroy(flags DestroyFlag) error {
 	if flags&DestroyForceUmount != 0 {
 		args = append(args, "-f")
 	}
+	return args
+}
 
-	args = append(args, d.Name)
-	err := zfs(args...)
-	return err
+// classifyHeld sets the code of err to CodeHeld if it failed as busy while one of the snapshots has user holds.
+func (c *Client) classifyHeld(ctx context.Context, err error, snapshots ...string) {
+	var e *Error
+	if !errors.As(err, &e) || e.Code != CodeBusy {
+		return
+	}
+	for _, name := range snapshots {
+		s := &Dataset{Name: name, Type: DatasetSnapshot, client: c}
+		if holds, herr := s.HoldsContext(ctx); herr == nil && len(holds) > 0 {
+			e.Code = CodeHeld
+			return
+		}
+	}
+}
+
+// DestroySnapshots destroys the snapshots of dataset with the given names, the part after the @, in batches with
+// `zfs destroy dataset@a,b,c`. Batches are kept well below the length limit of command line arguments, and stop at
+// the first one which fails. Names without a snapshot are skipped, but a batch fails if none of its snapshots is
+// found. Flags apply as in Dataset.Destroy.
+func DestroySnapshots(dataset string, names []string, flags DestroyFlag) error {
+	return defaultClient.DestroySnapshots(dataset, names, flags)
+}
+
+// DestroySnapshotsContext is like DestroySnapshots but includes a context.
+func DestroySnapshotsContext(ctx context.Context, dataset string, names []string, flags DestroyFlag) error {
+	return defaultClient.DestroySnapshotsContext(ctx, dataset, names, flags)
+}
+
+// DestroySnapshots destroys the snapshots of dataset with the given names, the part after the @, in batches with
+// `zfs destroy dataset@a,b,c`. Batches are kept well below the length limit of command line arguments, and stop at
+// the first one which fails. Names without a snapshot are skipped, but a batch fails if none of its snapshots is
+// found. Flags apply as in Dataset.Destroy.
+func (c *Client) DestroySnapshots(dataset string, names []string, flags DestroyFlag) error {
+	return c.DestroySnapshotsContext(context.Background(), dataset, names, flags)
+}
+
+// DestroySnapshotsContext is like DestroySnapshots but includes a context.
+func (c *Client) DestroySnapshotsContext(ctx context.Context, dataset string, names []string, flags DestroyFlag) error {
+	if len(names) == 0 {
+		return nil
+	}
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, "@,") {
+			return fmt.Errorf("invalid snapshot name: %q", name)
+		}
+	}
+	for _, batch := range snapshotBatches(dataset, names) {
+		err := c.zfs(ctx, append(destroyArgs(flags), dataset+"@"+strings.Join(batch, ","))...)
+		if err != nil {
+			snapshots := make([]string, len(batch))
+			for i, name := range batch {
+				snapshots[i] = dataset + "@" + name
+			}
+			c.classifyHeld(ctx, err, snapshots...)
+			return err
+		}
+	}
+	return nil
+}
+
+// Limits of the batches of DestroySnapshots. Linux caps a single argument at 128KiB, and transports such as ssh
+// pass the whole command line as one.
+var (
+	destroyBatchBytes = 32 << 10
+	destroyBatchCount = 1000
+)
+
+// snapshotBatches splits names into batches whose `dataset@a,b,c` argument fits the limits of DestroySnapshots.
+func snapshotBatches(dataset string, names []string) [][]string {
+	var batches [][]string
+	var batch []string
+	size := 0
+	for _, name := range names {
+		if len(batch) > 0 && (size+1+len(name) > destroyBatchBytes || len(batch) >= destroyBatchCount) {
+			batches = append(batches, batch)
+			batch = nil
+		}
+		if len(batch) == 0 {
+			size = len(dataset) + 1 + len(name)
+		} else {
+			size += 1 + len(name)
+		}
+		batch = append(batch, name)
+	}
+	return append(batches, batch)
 }
 
 // SetProperty sets a ZFS property on the receiving dataset.
@@ -302,8 +554,13 @@ func (d *Dataset) Destroy(flags DestroyFlag) error {
 // A full list of available ZFS properties may be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
 func (d *Dataset) SetProperty(key, val string) error {
+	return d.SetPropertyContext(context.Background(), key, val)
+}
+
+// SetPropertyContext is like SetProperty but includes a context.
+func (d *Dataset) SetPropertyContext(ctx context.Context, key, val string) error {
 	prop := strings.Join([]string{key, val}, "=")
-	err := zfs("set", prop, d.Name)
+	err := d.client.zfs(ctx, "set", prop, d.Name)
 	return err
 }
 
@@ -312,7 +569,12 @@ func (d *Dataset) SetProperty(key, val string) error {
 // A full list of available ZFS properties may be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
 func (d *Dataset) GetProperty(key string) (string, error) {
-	out, err := zfsOutput("get", "-Hp", key, d.Name)
+	return d.GetPropertyContext(context.Background(), key)
+}
+
+// GetPropertyContext is like GetProperty but includes a context.
+func (d *Dataset) GetPropertyContext(ctx context.Context, key string) (string, error) {
+	out, err := d.client.getOutput(ctx, d.client.zfsPath(), nil, key, d.Name)
 	if err != nil {
 		return "", err
 	}
@@ -322,6 +584,11 @@ func (d *Dataset) GetProperty(key string) (string, error) {
 
 // Rename renames a dataset.
 func (d *Dataset) Rename(name string, createParent, recursiveRenameSnapshots bool) (*Dataset, error) {
+	return d.RenameContext(context.Background(), name, createParent, recursiveRenameSnapshots)
+}
+
+// RenameContext is like Rename but includes a context.
+func (d *Dataset) RenameContext(ctx context.Context, name string, createParent, recursiveRenameSnapshots bool) (*Dataset, error) {
 	args := make([]string, 3, 5)
 	args[0] = "rename"
 	args[1] = d.Name
@@ -332,16 +599,22 @@ func (d *Dataset) Rename(name string, createParent, recursiveRenameSnapshots boo
 	if recursiveRenameSnapshots {
 		args = append(args, "-r")
 	}
-	if err := zfs(args...); err != nil {
+	if err := d.client.zfs(ctx, args...); err != nil {
 		return d, err
 	}
 
-	return GetDataset(name)
+	return d.client.GetDatasetContext(ctx, name)
 }
 
 // Snapshots returns a slice of all ZFS snapshots of a given dataset.
-func (d *Dataset) Snapshots() ([]*Dataset, error) {
-	return Snapshots(d.Name)
+// Extra property names may be given to populate the Extra field of each snapshot from the same zfs command.
+func (d *Dataset) Snapshots(extra ...string) ([]*Dataset, error) {
+	return d.SnapshotsContext(context.Background(), extra...)
+}
+
+// SnapshotsContext is like Snapshots but includes a context.
+func (d *Dataset) SnapshotsContext(ctx context.Context, extra ...string) ([]*Dataset, error) {
+	return d.client.SnapshotsContext(ctx, d.Name, extra...)
 }
 
 // CreateFilesystem creates a new ZFS filesystem with the specified name and properties.
@@ -349,6 +622,24 @@ func (d *Dataset) Snapshots() ([]*Dataset, error) {
 // A full list of available ZFS properties may be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
 func CreateFilesystem(name string, properties map[string]string) (*Dataset, error) {
+	return defaultClient.CreateFilesystem(name, properties)
+}
+
+// CreateFilesystemContext is like CreateFilesystem but includes a context.
+func CreateFilesystemContext(ctx context.Context, name string, properties map[string]string) (*Dataset, error) {
+	return defaultClient.CreateFilesystemContext(ctx, name, properties)
+}
+
+// CreateFilesystem creates a new ZFS filesystem with the specified name and properties.
+//
+// A full list of available ZFS properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
+func (c *Client) CreateFilesystem(name string, properties map[string]string) (*Dataset, error) {
+	return c.CreateFilesystemContext(context.Background(), name, properties)
+}
+
+// CreateFilesystemContext is like CreateFilesystem but includes a context.
+func (c *Client) CreateFilesystemContext(ctx context.Context, name string, properties map[string]string) (*Dataset, error) {
 	args := make([]string, 1, 4)
 	args[0] = "create"
 
@@ -357,15 +648,20 @@ func CreateFilesystem(name string, properties map[string]string) (*Dataset, erro
 	}
 
 	args = append(args, name)
-	if err := zfs(args...); err != nil {
+	if err := c.zfs(ctx, args...); err != nil {
 		return nil, err
 	}
-	return GetDataset(name)
+	return c.GetDatasetContext(ctx, name)
 }
 
 // Snapshot creates a new ZFS snapshot of the receiving dataset, using the specified name.
 // Optionally, the snapshot can be taken recursively, creating snapshots of all descendent filesystems in a single, atomic operation.
 func (d *Dataset) Snapshot(name string, recursive bool) (*Dataset, error) {
+	return d.SnapshotContext(context.Background(), name, recursive)
+}
+
+// SnapshotContext is like Snapshot but includes a context.
+func (d *Dataset) SnapshotContext(ctx context.Context, name string, recursive bool) (*Dataset, error) {
 	args := make([]string, 1, 4)
 	args[0] = "snapshot"
 	if recursive {
@@ -373,10 +669,10 @@ func (d *Dataset) Snapshot(name string, recursive bool) (*Dataset, error) {
 	}
 	snapName := fmt.Sprintf("%s@%s", d.Name, name)
 	args = append(args, snapName)
-	if err := zfs(args...); err != nil {
+	if err := d.client.zfs(ctx, args...); err != nil {
 		return nil, err
 	}
-	return GetDataset(snapName)
+	return d.client.GetDatasetContext(ctx, snapName)
 }
 
 // Rollback rolls back the receiving ZFS dataset to a previous snapshot.
@@ -384,6 +680,11 @@ func (d *Dataset) Snapshot(name string, recursive bool) (*Dataset, error) {
 // A ZFS snapshot rollback cannot be completed without this option, if more recent snapshots exist.
 // An error will be returned if the input dataset is not of snapshot type.
 func (d *Dataset) Rollback(destroyMoreRecent bool) error {
+	return d.RollbackContext(context.Background(), destroyMoreRecent)
+}
+
+// RollbackContext is like Rollback but includes a context.
+func (d *Dataset) RollbackContext(ctx context.Context, destroyMoreRecent bool) error {
 	if d.Type != DatasetSnapshot {
 		return errors.New("can only rollback snapshots")
 	}
@@ -395,40 +696,37 @@ func (d *Dataset) Rollback(destroyMoreRecent bool) error {
 	}
 	args = append(args, d.Name)
 
-	err := zfs(args...)
+	err := d.client.zfs(ctx, args...)
 	return err
 }
 
 // Children returns a slice of children of the receiving ZFS dataset.
 // A recursion depth may be specified, or a depth of 0 allows unlimited recursion.
-func (d *Dataset) Children(depth uint64) ([]*Dataset, error) {
-	args := []string{"list"}
+// Extra property names may be given to populate the Extra field of each child from the same zfs command.
+func (d *Dataset) Children(depth uint64, extra ...string) ([]*Dataset, error) {
+	return d.ChildrenContext(context.Background(), depth, extra...)
+}
+
+// ChildrenContext is like Children but includes a context.
+func (d *Dataset) ChildrenContext(ctx context.Context, depth uint64, extra ...string) ([]*Dataset, error) {
+	var args []string
 	if depth > 0 {
 		args = append(args, "-d")
 		args = append(args, strconv.FormatUint(depth, 10))
 	} else {
 		args = append(args, "-r")
 	}
-	args = append(args, "-t", "all", "-Hp", "-o", dsPropListOptions)
+	args = append(args, "-t", "all")
 	args = append(args, d.Name)
 
-	out, err := zfsOutput(args...)
+	out, err := d.client.listOutput(ctx, extra, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	var datasets []*Dataset
-	name := ""
-	var ds *Dataset
-	for _, line := range out {
-		if name != line[0] {
-			name = line[0]
-			ds = &Dataset{Name: name}
-			datasets = append(datasets, ds)
-		}
-		if err := ds.parseLine(line); err != nil {
-			return nil, err
-		}
+	datasets, err := d.client.datasets(out, extra)
+	if err != nil {
+		return nil, err
 	}
 	return datasets[1:], nil
 }
@@ -436,8 +734,13 @@ func (d *Dataset) Children(depth uint64) ([]*Dataset, error) {
 // Diff returns changes between a snapshot and the given ZFS dataset.
 // The snapshot name must include the filesystem part as it is possible to compare clones with their origin snapshots.
 func (d *Dataset) Diff(snapshot string) ([]*InodeChange, error) {
+	return d.DiffContext(context.Background(), snapshot)
+}
+
+// DiffContext is like Diff but includes a context.
+func (d *Dataset) DiffContext(ctx context.Context, snapshot string) ([]*InodeChange, error) {
 	args := []string{"diff", "-FH", snapshot, d.Name}
-	out, err := zfsOutput(args...)
+	out, err := d.client.zfsOutput(ctx, args...)
 	if err != nil {
 		return nil, err
 	}
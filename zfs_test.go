@@ -1,11 +1,14 @@
 package zfs_test
 
 import (
+	"bytes"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"testing"
 
 	zfs "github.com/mistifyio/go-zfs/v4"
@@ -26,6 +29,57 @@ func TestDatasets(t *testing.T) {
 	}
 }
 
+func TestDatasetsExtra(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/extra-test", map[string]string{"com.example:owner": "alice"})
+	ok(t, err)
+	_, err = f.Snapshot("snap", false)
+	ok(t, err)
+
+	for _, c := range []*zfs.Client{{}, {DisableJSON: true}} {
+		datasets, err := c.Datasets("test/extra-test", "creation", "recsize", "com.example:owner")
+		ok(t, err)
+		equals(t, 2, len(datasets))
+		equals(t, "131072", datasets[0].Extra["recsize"])
+		equals(t, "alice", datasets[1].Extra["com.example:owner"])
+		_, err = strconv.ParseInt(datasets[1].Extra["creation"], 10, 64)
+		ok(t, err)
+
+		ds, err := c.GetDataset("test/extra-test", "usedbysnapshots")
+		ok(t, err)
+		equals(t, 1, len(ds.Extra))
+
+		children, err := ds.Children(0, "createtxg")
+		ok(t, err)
+		equals(t, 1, len(children))
+		equals(t, true, children[0].Extra["createtxg"] != "-")
+
+		snapshots, err := ds.Snapshots("userrefs")
+		ok(t, err)
+		equals(t, 1, len(snapshots))
+		equals(t, "0", snapshots[0].Extra["userrefs"])
+	}
+
+	pool, err := zfs.GetZpool("test")
+	ok(t, err)
+	datasets, err := pool.Datasets("com.example:owner")
+	ok(t, err)
+	for _, ds := range datasets {
+		if ds.Name == "test/extra-test" {
+			equals(t, "alice", ds.Extra["com.example:owner"])
+		}
+	}
+	snapshots, err := pool.Snapshots("createtxg")
+	ok(t, err)
+	equals(t, true, len(snapshots) > 0)
+	for _, s := range snapshots {
+		equals(t, true, s.Extra["createtxg"] != "")
+	}
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
 func TestDatasetGetProperty(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -50,6 +104,132 @@ func TestDatasetGetProperty(t *testing.T) {
 	}
 }
 
+func TestDatasetProperties(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	parent, err := zfs.CreateFilesystem("test/properties-test", map[string]string{"compression": "lz4"})
+	ok(t, err)
+	child, err := zfs.CreateFilesystem("test/properties-test/child", nil)
+	ok(t, err)
+
+	props, err := child.GetProperties("compression", "used")
+	ok(t, err)
+	equals(t, 2, len(props))
+	equals(t, zfs.Property{Name: "compression", Value: "lz4", Received: "-", Source: zfs.SourceInherited, InheritedFrom: "test/properties-test"}, props["compression"])
+	equals(t, zfs.SourceNone, props["used"].Source)
+
+	ok(t, child.SetProperties(map[string]string{"compression": "off", "com.example:owner": "alice"}))
+	props, err = child.GetAllProperties()
+	ok(t, err)
+	equals(t, zfs.Property{Name: "compression", Value: "off", Received: "-", Source: zfs.SourceLocal}, props["compression"])
+	equals(t, "alice", props["com.example:owner"].Value)
+
+	ok(t, child.InheritProperty("compression", false, false))
+	props, err = child.GetProperties("compression")
+	ok(t, err)
+	equals(t, "lz4", props["compression"].Value)
+	equals(t, zfs.SourceInherited, props["compression"].Source)
+
+	ok(t, parent.InheritProperty("compression", true, false))
+	props, err = child.GetProperties("compression")
+	ok(t, err)
+	equals(t, zfs.SourceDefault, props["compression"].Source)
+
+	ok(t, parent.Destroy(zfs.DestroyRecursive))
+}
+
+func TestListProperties(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/list-properties-test", map[string]string{"compression": "lz4"})
+	ok(t, err)
+	_, err = zfs.CreateFilesystem("test/list-properties-test/child", nil)
+	ok(t, err)
+	_, err = f.Snapshot("snap", false)
+	ok(t, err)
+
+	datasets, err := zfs.ListProperties("test/list-properties-test", 0, "", "compression", "used")
+	ok(t, err)
+	equals(t, 3, len(datasets))
+	equals(t, 2, len(datasets["test/list-properties-test@snap"]))
+	equals(t, "test/list-properties-test", datasets["test/list-properties-test/child"]["compression"].InheritedFrom)
+
+	datasets, err = zfs.ListProperties("test/list-properties-test", 1, zfs.DatasetFilesystem, "compression")
+	ok(t, err)
+	equals(t, 2, len(datasets))
+	equals(t, zfs.SourceLocal, datasets["test/list-properties-test"]["compression"].Source)
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestUserProperties(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	parent, err := zfs.CreateFilesystem("test/user-properties-test", nil)
+	ok(t, err)
+	child, err := zfs.CreateFilesystem("test/user-properties-test/child", nil)
+	ok(t, err)
+
+	nok(t, parent.SetUserProperty("owner", "alice"))
+	ok(t, parent.SetUserProperty("com.example:owner", "alice"))
+	ok(t, parent.SetUserProperty("org.other:tenant", "blue"))
+	ok(t, child.SetUserProperty("com.example:owner", "bob"))
+
+	value, set, err := child.GetUserProperty("com.example:owner")
+	ok(t, err)
+	equals(t, true, set)
+	equals(t, "bob", value)
+
+	_, set, err = child.GetUserProperty("com.example:missing")
+	ok(t, err)
+	equals(t, false, set)
+
+	props, err := child.UserProperties("com.example")
+	ok(t, err)
+	equals(t, 1, len(props))
+	equals(t, zfs.SourceLocal, props["com.example:owner"].Source)
+
+	datasets, err := zfs.DatasetsWithUserProperty("test/user-properties-test", "com.example:owner", "alice")
+	ok(t, err)
+	equals(t, 1, len(datasets))
+	equals(t, "test/user-properties-test", datasets[0].Name)
+
+	ok(t, child.UnsetUserProperty("com.example:owner", false))
+	value, _, err = child.GetUserProperty("com.example:owner")
+	ok(t, err)
+	equals(t, "alice", value)
+
+	ok(t, parent.Destroy(zfs.DestroyRecursive))
+}
+
+func TestTypedDatasetProperties(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	quota, err := zfs.ParseSize("1G")
+	ok(t, err)
+	want := &zfs.DatasetProperties{
+		Compression: zfs.GzipLevel(6),
+		RecordSize:  1 << 20,
+		Atime:       zfs.Off,
+		Sync:        zfs.SyncDisabled,
+		Xattr:       zfs.XattrSA,
+		Copies:      2,
+		Quota:       &quota,
+	}
+	props, err := want.Map()
+	ok(t, err)
+	f, err := zfs.CreateFilesystem("test/dataset-properties-test", props)
+	ok(t, err)
+
+	got, err := f.GetDatasetProperties()
+	ok(t, err)
+	var none zfs.Size
+	want.RefQuota, want.Reservation, want.RefReservation = &none, &none, &none
+	equals(t, want, got)
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
 func TestSnapshots(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -205,6 +385,480 @@ func TestSendSnapshot(t *testing.T) {
 	ok(t, f.Destroy(zfs.DestroyDefault))
 }
 
+func TestBookmark(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/bookmark-test", nil)
+	ok(t, err)
+
+	s1, err := f.Snapshot("one", false)
+	ok(t, err)
+
+	b, err := s1.Bookmark("one")
+	ok(t, err)
+	equals(t, zfs.DatasetBookmark, b.Type)
+	equals(t, "test/bookmark-test#one", b.Name)
+
+	_, err = f.Bookmark("fs")
+	nok(t, err)
+
+	bookmarks, err := zfs.Bookmarks("test/bookmark-test")
+	ok(t, err)
+	equals(t, 1, len(bookmarks))
+	equals(t, b.Name, bookmarks[0].Name)
+
+	ok(t, s1.Destroy(zfs.DestroyDefault))
+
+	s2, err := f.Snapshot("two", false)
+	ok(t, err)
+
+	var buf bytes.Buffer
+	ok(t, s2.IncrementalSend(b, &buf))
+
+	ok(t, b.Destroy(zfs.DestroyDefault))
+	bookmarks, err = zfs.Bookmarks("test/bookmark-test")
+	ok(t, err)
+	equals(t, 0, len(bookmarks))
+
+	ok(t, s2.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestHolds(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/hold-test", nil)
+	ok(t, err)
+	child, err := zfs.CreateFilesystem("test/hold-test/child", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("snap", true)
+	ok(t, err)
+	ok(t, s.Hold("backup", true))
+	nok(t, s.Hold("backup", false))
+	nok(t, f.Hold("backup", false))
+
+	holds, err := s.Holds()
+	ok(t, err)
+	equals(t, 1, len(holds))
+	equals(t, "test/hold-test@snap", holds[0].Name)
+	equals(t, "backup", holds[0].Tag)
+
+	childSnap, err := zfs.GetDataset("test/hold-test/child@snap")
+	ok(t, err)
+	holds, err = childSnap.Holds()
+	ok(t, err)
+	equals(t, 1, len(holds))
+
+	err = s.Destroy(zfs.DestroyDefault)
+	nok(t, err)
+	equals(t, true, errors.Is(err, zfs.ErrHeld))
+	equals(t, true, errors.Is(err, zfs.ErrBusy))
+
+	ok(t, childSnap.Release("backup", false))
+	ok(t, childSnap.Destroy(zfs.DestroyDefault))
+	nok(t, s.Release("nope", false))
+
+	ok(t, s.Destroy(zfs.DestroyDeferDeletion))
+	deferred, err := s.GetProperty("defer_destroy")
+	ok(t, err)
+	equals(t, "on", deferred)
+
+	ok(t, s.Release("backup", false))
+	_, err = zfs.GetDataset(s.Name)
+	equals(t, true, errors.Is(err, zfs.ErrNotFound))
+
+	ok(t, child.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestSendOptions(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/send-src", map[string]string{"compression": "lz4"})
+	ok(t, err)
+	_, err = zfs.CreateFilesystem("test/send-src/child", nil)
+	ok(t, err)
+	_, err = f.Snapshot("s1", true)
+	ok(t, err)
+	_, err = f.Snapshot("s2", true)
+	ok(t, err)
+	s3, err := f.Snapshot("s3", true)
+	ok(t, err)
+
+	var buf bytes.Buffer
+	ok(t, s3.Send(&buf, zfs.SendOptions{Replicate: true}))
+	dst, err := zfs.ReceiveSnapshot(&buf, "test/send-dst")
+	ok(t, err)
+	snapshots, err := zfs.Snapshots("test/send-dst")
+	ok(t, err)
+	equals(t, 6, len(snapshots))
+	compression, err := dst.GetProperty("compression")
+	ok(t, err)
+	equals(t, "lz4", compression)
+
+	s4, err := f.Snapshot("s4", true)
+	ok(t, err)
+	buf.Reset()
+	ok(t, s4.Send(&buf, zfs.SendOptions{Base: s3, Intermediate: true, Replicate: true}))
+	_, err = zfs.ReceiveSnapshot(&buf, "test/send-dst")
+	ok(t, err)
+	_, err = zfs.GetDataset("test/send-dst/child@s4")
+	ok(t, err)
+
+	nok(t, f.Send(&buf, zfs.SendOptions{}))
+
+	ok(t, dst.Destroy(zfs.DestroyRecursive))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestReceiveOptions(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	_, err := zfs.CreateFilesystem("test/recv-src", nil)
+	ok(t, err)
+	f, err := zfs.CreateFilesystem("test/recv-src/a", map[string]string{"compression": "lz4", "atime": "off"})
+	ok(t, err)
+	s1, err := f.Snapshot("s1", false)
+	ok(t, err)
+	s2, err := f.Snapshot("s2", false)
+	ok(t, err)
+	dst, err := zfs.CreateFilesystem("test/recv-dst", nil)
+	ok(t, err)
+
+	var buf bytes.Buffer
+	ok(t, s2.Send(&buf, zfs.SendOptions{Base: s1, Intermediate: true}))
+	incremental := buf.Bytes()
+	var full bytes.Buffer
+	ok(t, s1.Send(&full, zfs.SendOptions{Props: true}))
+
+	res, err := zfs.Receive(bytes.NewReader(full.Bytes()), "test/recv-dst", zfs.ReceiveOptions{DiscardFirst: true, DryRun: true})
+	ok(t, err)
+	equals(t, []zfs.ReceivedStream{{Source: "test/recv-src/a@s1", Target: "test/recv-dst/recv-src/a@s1"}}, res.Streams)
+	equals(t, (*zfs.Dataset)(nil), res.Dataset)
+	_, err = zfs.GetDataset("test/recv-dst/recv-src")
+	nok(t, err)
+
+	res, err = zfs.Receive(bytes.NewReader(full.Bytes()), "test/recv-dst", zfs.ReceiveOptions{
+		DiscardFirst: true,
+		NoMount:      true,
+		Props:        map[string]string{"compression": "gzip"},
+		Exclude:      []string{"atime"},
+	})
+	ok(t, err)
+	equals(t, "test/recv-dst/recv-src/a", res.Dataset.Name)
+	compression, err := res.Dataset.GetProperty("compression")
+	ok(t, err)
+	equals(t, "gzip", compression)
+	atime, err := res.Dataset.GetProperty("atime")
+	ok(t, err)
+	equals(t, "on", atime)
+
+	res, err = zfs.Receive(bytes.NewReader(full.Bytes()), "test/recv-dst", zfs.ReceiveOptions{LastElement: true})
+	ok(t, err)
+	equals(t, "test/recv-dst/a", res.Dataset.Name)
+	res, err = zfs.Receive(bytes.NewReader(incremental), "test/recv-dst/a", zfs.ReceiveOptions{Force: true})
+	ok(t, err)
+	equals(t, "test/recv-dst/a", res.Dataset.Name)
+	equals(t, 1, len(res.Streams))
+	equals(t, true, res.Streams[0].Incremental)
+
+	_, err = zfs.Receive(bytes.NewReader(full.Bytes()), "test/recv-dst@s1", zfs.ReceiveOptions{LastElement: true})
+	nok(t, err)
+
+	ok(t, dst.Destroy(zfs.DestroyRecursive))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestResumable(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/resumable", nil)
+	ok(t, err)
+	s, err := f.Snapshot("s1", false)
+	ok(t, err)
+	var stream bytes.Buffer
+	ok(t, s.SendSnapshot(&stream))
+	interrupt := func(name string) *zfs.Dataset {
+		t.Helper()
+		_, err := zfs.Receive(bytes.NewReader(stream.Bytes()[:stream.Len()/2]), name, zfs.ReceiveOptions{Resumable: true})
+		nok(t, err)
+		partial, err := zfs.GetDataset(name)
+		ok(t, err)
+		return partial
+	}
+
+	partial := interrupt("test/resumable-resumed")
+	token, err := partial.ResumeToken()
+	ok(t, err)
+	equals(t, true, token != "")
+	var rest bytes.Buffer
+	ok(t, zfs.ResumeSend(token, &rest))
+	equals(t, true, rest.Len() < stream.Len())
+	res, err := zfs.Receive(&rest, "test/resumable-resumed", zfs.ReceiveOptions{Resumable: true})
+	ok(t, err)
+	equals(t, "test/resumable-resumed", res.Dataset.Name)
+	token, err = res.Dataset.ResumeToken()
+	ok(t, err)
+	equals(t, "", token)
+	_, err = zfs.GetDataset("test/resumable-resumed@s1")
+	ok(t, err)
+
+	partial = interrupt("test/resumable-aborted")
+	ok(t, partial.AbortReceive())
+	_, err = zfs.GetDataset("test/resumable-aborted")
+	equals(t, true, errors.Is(err, zfs.ErrNotFound))
+
+	// SendResumable resumes from the state left by an earlier interrupted transfer.
+	interrupt("test/resumable-retried")
+	res, err = s.SendResumable(nil, "test/resumable-retried", zfs.ResumableOptions{})
+	ok(t, err)
+	equals(t, "test/resumable-retried", res.Dataset.Name)
+	_, err = zfs.GetDataset("test/resumable-retried@s1")
+	ok(t, err)
+
+	// Partially received state from another snapshot is not resumed.
+	stale := interrupt("test/resumable-stale")
+	s2, err := f.Snapshot("s2", false)
+	ok(t, err)
+	_, err = s2.SendResumable(nil, "test/resumable-stale", zfs.ResumableOptions{})
+	nok(t, err)
+	equals(t, true, strings.Contains(err.Error(), "from test/resumable@s1, not test/resumable@s2"))
+	token, err = stale.ResumeToken()
+	ok(t, err)
+	equals(t, true, token != "")
+	ok(t, stale.AbortReceive())
+
+	for _, name := range []string{"test/resumable-resumed", "test/resumable-retried", "test/resumable"} {
+		d, err := zfs.GetDataset(name)
+		ok(t, err)
+		ok(t, d.Destroy(zfs.DestroyRecursive))
+	}
+}
+
+func TestEstimateSend(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/estimate", nil)
+	ok(t, err)
+	_, err = zfs.CreateFilesystem("test/estimate/child", nil)
+	ok(t, err)
+	s1, err := f.Snapshot("s1", true)
+	ok(t, err)
+	_, err = f.Snapshot("s2", true)
+	ok(t, err)
+	s3, err := f.Snapshot("s3", true)
+	ok(t, err)
+
+	for _, opts := range []zfs.SendOptions{
+		{},
+		{Base: s1},
+		{Base: s1, Intermediate: true, Replicate: true},
+	} {
+		estimate, err := s3.EstimateSend(opts)
+		ok(t, err)
+		equals(t, true, len(estimate.Streams) > 0)
+
+		var buf bytes.Buffer
+		ok(t, s3.Send(&buf, opts))
+		if emulated {
+			equals(t, uint64(buf.Len()), estimate.Size)
+		}
+	}
+
+	estimate, err := s3.EstimateSend(zfs.SendOptions{Base: s1, Intermediate: true, Replicate: true})
+	ok(t, err)
+	equals(t, 4, len(estimate.Streams))
+	equals(t, "test/estimate@s2", estimate.Streams[0].Snapshot)
+	equals(t, "test/estimate/child@s3", estimate.Streams[3].Snapshot)
+	equals(t, true, estimate.Streams[3].Base != "")
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestProgress(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/progress", nil)
+	ok(t, err)
+	s, err := f.Snapshot("s1", false)
+	ok(t, err)
+
+	var sent, received []zfs.Progress
+	var buf bytes.Buffer
+	ok(t, s.Send(&buf, zfs.SendOptions{Progress: &zfs.ProgressOptions{
+		Func: func(p zfs.Progress) { sent = append(sent, p) },
+	}}))
+	equals(t, true, len(sent) > 0)
+	last := sent[len(sent)-1]
+	equals(t, true, last.Done)
+	equals(t, uint64(buf.Len()), last.Bytes)
+	if emulated {
+		equals(t, last.Bytes, last.Total)
+	}
+
+	n := buf.Len()
+	_, err = zfs.Receive(&buf, "test/progress-copy", zfs.ReceiveOptions{Progress: &zfs.ProgressOptions{
+		Func:  func(p zfs.Progress) { received = append(received, p) },
+		Total: uint64(n),
+	}})
+	ok(t, err)
+	equals(t, true, len(received) > 0)
+	last = received[len(received)-1]
+	equals(t, true, last.Done)
+	equals(t, uint64(n), last.Bytes)
+	equals(t, uint64(n), last.Total)
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestReplicate(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/replicate", nil)
+	ok(t, err)
+	s, err := f.Snapshot("s1", false)
+	ok(t, err)
+
+	res, err := zfs.Replicate(s, "test/replicate-copy", zfs.ReplicateOptions{})
+	ok(t, err)
+	equals(t, "test/replicate-copy", res.Dataset.Name)
+	equals(t, 1, len(res.Streams))
+	snapshots, err := res.Dataset.Snapshots()
+	ok(t, err)
+	equals(t, 1, len(snapshots))
+	equals(t, "test/replicate-copy@s1", snapshots[0].Name)
+
+	_, err = zfs.Replicate(s, "test/replicate-copy", zfs.ReplicateOptions{})
+	nok(t, err)
+	var rerr *zfs.ReplicationError
+	equals(t, true, errors.As(err, &rerr))
+	equals(t, true, rerr.Receive != nil)
+	equals(t, true, rerr.ReceiveStderr != "")
+
+	ok(t, res.Dataset.Destroy(zfs.DestroyRecursive))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestSync(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/sync", nil)
+	ok(t, err)
+	_, err = f.Snapshot("a", false)
+	ok(t, err)
+	_, err = f.Snapshot("b", false)
+	ok(t, err)
+
+	res, err := f.Sync(nil, "test/sync-copy", zfs.SyncOptions{})
+	ok(t, err)
+	equals(t, true, res.Common == nil)
+	equals(t, "test/sync@b", res.Latest.Name)
+	equals(t, 2, len(res.Streams))
+	equals(t, false, res.Streams[0].Incremental)
+	equals(t, true, res.Streams[1].Incremental)
+
+	_, err = f.Snapshot("c", false)
+	ok(t, err)
+	res, err = f.Sync(nil, "test/sync-copy", zfs.SyncOptions{Snapshot: "d"})
+	ok(t, err)
+	equals(t, "test/sync@b", res.Common.Name)
+	equals(t, "test/sync@d", res.Latest.Name)
+	equals(t, 2, len(res.Streams))
+	equals(t, "test/sync-copy@d", res.Streams[1].Target)
+
+	res, err = f.Sync(nil, "test/sync-copy", zfs.SyncOptions{})
+	ok(t, err)
+	equals(t, res.Latest.Name, res.Common.Name)
+	equals(t, 0, len(res.Streams))
+
+	// Snapshots are matched by guid rather than by name.
+	copied, err := zfs.GetDataset("test/sync-copy@d")
+	ok(t, err)
+	_, err = copied.Rename("test/sync-copy@renamed", false, false)
+	ok(t, err)
+	res, err = f.Sync(nil, "test/sync-copy", zfs.SyncOptions{Snapshot: "e"})
+	ok(t, err)
+	equals(t, "test/sync@d", res.Common.Name)
+	equals(t, 1, len(res.Streams))
+
+	other, err := zfs.CreateFilesystem("test/sync-other", nil)
+	ok(t, err)
+	_, err = other.Snapshot("x", false)
+	ok(t, err)
+	_, err = other.Sync(nil, "test/sync-copy", zfs.SyncOptions{Snapshot: "stray"})
+	equals(t, true, errors.Is(err, zfs.ErrNoCommonSnapshot))
+	_, err = zfs.GetDataset("test/sync-other@stray")
+	equals(t, true, errors.Is(err, zfs.ErrNotFound))
+
+	res, err = other.Sync(nil, "test/sync-copy", zfs.SyncOptions{NoCommon: zfs.NoCommonRename})
+	ok(t, err)
+	equals(t, true, res.Renamed != "")
+	renamed, err := zfs.GetDataset(res.Renamed + "@renamed")
+	ok(t, err)
+	ok(t, renamed.Destroy(zfs.DestroyDefault))
+	renamed, err = zfs.GetDataset(res.Renamed)
+	ok(t, err)
+	ok(t, renamed.Destroy(zfs.DestroyRecursive))
+	_, err = zfs.GetDataset("test/sync-copy@x")
+	ok(t, err)
+
+	// A failed replacement leaves the target untouched, without the dataset received under a temporary name.
+	_, err = f.Sync(nil, "test/sync-copy", zfs.SyncOptions{
+		NoCommon: zfs.NoCommonFullSend,
+		Receive:  zfs.ReceiveOptions{Props: map[string]string{"notaproperty": "on"}},
+	})
+	nok(t, err)
+	_, err = zfs.GetDataset("test/sync-copy@x")
+	ok(t, err)
+	filesystems, err := zfs.Filesystems("test")
+	ok(t, err)
+	for _, fs := range filesystems {
+		equals(t, false, strings.Contains(fs.Name, "-syncing-"))
+	}
+
+	res, err = f.Sync(nil, "test/sync-copy", zfs.SyncOptions{NoCommon: zfs.NoCommonFullSend})
+	ok(t, err)
+	equals(t, true, res.Common == nil)
+	snapshots, err := zfs.Snapshots("test/sync-copy")
+	ok(t, err)
+	equals(t, 5, len(snapshots))
+
+	copied, err = zfs.GetDataset("test/sync-copy")
+	ok(t, err)
+	for _, d := range []*zfs.Dataset{f, other, copied} {
+		ok(t, d.Destroy(zfs.DestroyRecursive))
+	}
+}
+
+func TestDestroySnapshots(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/destroy-snapshots", nil)
+	ok(t, err)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		_, err = f.Snapshot(name, false)
+		ok(t, err)
+	}
+	held, err := zfs.GetDataset("test/destroy-snapshots@d")
+	ok(t, err)
+	ok(t, held.Hold("keep", false))
+
+	ok(t, zfs.DestroySnapshots(f.Name, nil, zfs.DestroyDefault))
+	ok(t, zfs.DestroySnapshots(f.Name, []string{"a", "c", "missing"}, zfs.DestroyDefault))
+	snapshots, err := f.Snapshots()
+	ok(t, err)
+	equals(t, 2, len(snapshots))
+	equals(t, "test/destroy-snapshots@b", snapshots[0].Name)
+
+	err = zfs.DestroySnapshots(f.Name, []string{"b", "d"}, zfs.DestroyDefault)
+	equals(t, true, errors.Is(err, zfs.ErrHeld))
+	nok(t, zfs.DestroySnapshots(f.Name, []string{"b,d"}, zfs.DestroyDefault))
+
+	ok(t, held.Release("keep", false))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
 func TestChildren(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -278,6 +932,9 @@ func TestRollback(t *testing.T) {
 }
 
 func TestDiff(t *testing.T) {
+	if emulated {
+		t.Skip("zfs diff is not supported by the emulator")
+	}
 	defer setupZPool(t).cleanUp()
 
 	fs, err := zfs.CreateFilesystem("test/origin", nil)
@@ -0,0 +1,457 @@
+package zfstest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+)
+
+// Emulator is a zfs.Executor which emulates the subset of the `zfs` and `zpool` commands issued by go-zfs against an
+// in-memory set of pools and datasets.
+//
+// The emulator tracks the dataset hierarchy, snapshots, clones and their origins, bookmarks, snapshot holds, and
+// property inheritance. It does not store any file data: space accounting is synthetic, send streams only describe
+// the snapshot being sent, and commands which inspect file data, such as `zfs diff`, are not supported.
+//
+// Commands run through the privilege escalation tools sudo, doas and pfexec, as set by zfs.Runner.Prefix, are
+// emulated as if run directly, unless Refuse is set.
+type Emulator struct {
+	// Now returns the current time, used for the creation property. If nil, time.Now is used.
+	Now func() time.Time
+
+	// Refuse, if not empty, is printed by the privilege escalation tools, which then fail with exit code 1 rather
+	// than run the command, as sudo does with "sudo: a password is required".
+	Refuse string
+
+	// Version is the OpenZFS version reported by `zfs version`, such as "2.1.5". JSON output is only accepted from
+	// 2.3. If empty, DefaultVersion is used.
+	Version string
+
+	mu       sync.Mutex
+	pools    map[string]*emuPool
+	datasets map[string]*emuDataset
+	txg      uint64
+}
+
+type emuPool struct {
+	name  string
+	size  uint64
+	props map[string]string
+}
+
+type emuDataset struct {
+	name       string
+	typ        string
+	origin     string
+	creation   int64
+	createtxg  uint64
+	guid       uint64
+	referenced uint64
+	props      map[string]string
+	// holds maps the tags of the user holds on a snapshot to the time they were placed.
+	holds map[string]time.Time
+	// partial is the state saved by an interrupted `zfs receive -s` into the dataset.
+	partial *emuPartial
+}
+
+// NewEmulator returns an Emulator without any pools.
+func NewEmulator() *Emulator {
+	return &Emulator{
+		pools:    map[string]*emuPool{},
+		datasets: map[string]*emuDataset{},
+	}
+}
+
+// usageError is returned by command handlers, its message is written to stderr and the command exits with code.
+type usageError struct {
+	code int
+	msg  string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func failf(format string, a ...interface{}) error {
+	return &usageError{code: 1, msg: fmt.Sprintf(format, a...)}
+}
+
+func usagef(format string, a ...interface{}) error {
+	return &usageError{code: 2, msg: fmt.Sprintf(format, a...)}
+}
+
+// emuCmd is the state of a single command handled by the Emulator.
+type emuCmd struct {
+	args   []string
+	stdin  io.Reader
+	stdout bytes.Buffer
+	raw    io.Writer
+}
+
+func (c *emuCmd) printf(format string, a ...interface{}) {
+	fmt.Fprintf(&c.stdout, format, a...)
+}
+
+// Exec implements zfs.Executor.
+func (e *Emulator) Exec(ctx context.Context, cmd *zfs.Cmd) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, args, escalated := stripPrefix(cmd.Path, cmd.Args)
+	c := &emuCmd{args: args, stdin: cmd.Stdin, raw: cmd.Stdout}
+	if c.stdin == nil {
+		c.stdin = strings.NewReader("")
+	}
+
+	var err error
+	switch {
+	case escalated && e.Refuse != "":
+		err = failf("%s", e.Refuse)
+	case len(args) == 0:
+		err = usagef("missing command")
+	default:
+		switch name := path[strings.LastIndex(path, "/")+1:]; name {
+		case "zfs":
+			err = e.zfs(c)
+		case "zpool":
+			err = e.zpool(c)
+		default:
+			err = &usageError{code: 127, msg: fmt.Sprintf("%s: command not found", name)}
+		}
+	}
+
+	if cmd.Stdout != nil && c.stdout.Len() > 0 {
+		if _, werr := cmd.Stdout.Write(c.stdout.Bytes()); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	var uerr *usageError
+	if !errors.As(err, &uerr) {
+		return err
+	}
+	if cmd.Stderr != nil {
+		msg := uerr.msg
+		if !strings.HasSuffix(msg, "\n") {
+			msg += "\n"
+		}
+		if _, werr := io.WriteString(cmd.Stderr, msg); werr != nil {
+			return werr
+		}
+	}
+	return &ExitError{Code: uerr.code}
+}
+
+// stripPrefix returns the command run by the privilege escalation tool path with args, or path and args themselves
+// if path is not such a tool, and whether a tool was found.
+func stripPrefix(path string, args []string) (string, []string, bool) {
+	switch path[strings.LastIndex(path, "/")+1:] {
+	case "sudo", "doas", "pfexec":
+	default:
+		return path, args, false
+	}
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		opt := args[0]
+		args = args[1:]
+		if opt == "--" {
+			break
+		}
+		// Options of sudo and doas taking a separate argument.
+		if (opt == "-u" || opt == "-g" || opt == "-C") && len(args) > 0 {
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return path, args, true
+	}
+	return args[0], args[1:], true
+}
+
+func (e *Emulator) now() time.Time {
+	if e.Now != nil {
+		return e.Now()
+	}
+	return time.Now()
+}
+
+// getopt parses args the way the ZFS tools do. Flags may be grouped, flags followed by ':' in spec take a value,
+// and operands may be interleaved with flags.
+func getopt(args []string, spec string) (map[byte][]string, []string, error) {
+	opts := map[byte][]string{}
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			operands = append(operands, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			operands = append(operands, arg)
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			flag := arg[j]
+			k := strings.IndexByte(spec, flag)
+			if k < 0 || flag == ':' {
+				return nil, nil, usagef("invalid option '%c'", flag)
+			}
+			if k+1 < len(spec) && spec[k+1] == ':' {
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 >= len(args) {
+						return nil, nil, usagef("missing argument for '%c' option", flag)
+					}
+					i++
+					value = args[i]
+				}
+				opts[flag] = append(opts[flag], value)
+				break
+			}
+			opts[flag] = append(opts[flag], "")
+		}
+	}
+	return opts, operands, nil
+}
+
+// parseProps parses k=v assignments as passed to -o.
+func parseProps(assignments []string) (map[string]string, error) {
+	props := map[string]string{}
+	for _, a := range assignments {
+		i := strings.IndexByte(a, '=')
+		if i <= 0 {
+			return nil, usagef("missing '=' for property=value argument")
+		}
+		props[a[:i]] = a[i+1:]
+	}
+	return props, nil
+}
+
+// parseSize parses a number with an optional binary unit suffix, as accepted by the ZFS tools.
+func parseSize(s string) (uint64, error) {
+	if s == "none" {
+		return 0, nil
+	}
+	num := strings.TrimRight(strings.ToUpper(s), "B")
+	if num == "" {
+		num = "0"
+	}
+	shift := 0
+	if i := strings.IndexByte("KMGTPE", num[len(num)-1]); i >= 0 {
+		shift = 10 * (i + 1)
+		num = num[:len(num)-1]
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("bad numeric value '%s'", s)
+	}
+	v := f * math.Pow(2, float64(shift))
+	if v >= math.MaxUint64 {
+		return 0, fmt.Errorf("numeric value is too large")
+	}
+	return uint64(v), nil
+}
+
+// datasetOf returns the dataset part of a snapshot name, or name itself.
+func datasetOf(name string) string {
+	if i := strings.IndexAny(name, "@#"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// parentOf returns the name of the parent of a filesystem or volume, or "" for the root of a pool.
+func parentOf(name string) string {
+	i := strings.LastIndexByte(name, '/')
+	if i < 0 {
+		return ""
+	}
+	return name[:i]
+}
+
+func poolOf(name string) string {
+	if i := strings.IndexAny(name, "/@#"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func depthOf(name string) int {
+	d := strings.Count(datasetOf(name), "/")
+	if name != datasetOf(name) {
+		d++
+	}
+	return d
+}
+
+func validName(name string) bool {
+	if name == "" || strings.Count(name, "@")+strings.Count(name, "#") > 1 {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '@' || r == '#' }) {
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-.: ", r)) {
+				return false
+			}
+		}
+	}
+	return !strings.Contains(name, "//") && !strings.HasSuffix(name, "/") && !strings.HasPrefix(name, "/")
+}
+
+func (e *Emulator) lookup(name string) (*emuDataset, error) {
+	ds, ok := e.datasets[name]
+	if !ok {
+		return nil, failf("cannot open '%s': dataset does not exist", name)
+	}
+	return ds, nil
+}
+
+func (e *Emulator) nextTxg() uint64 {
+	e.txg++
+	return e.txg
+}
+
+func (e *Emulator) newGUID(name string) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%d\x00%d", name, e.txg, e.now().UnixNano())
+	return h.Sum64()
+}
+
+// add creates a new dataset with the given name and type.
+func (e *Emulator) add(name, typ string, props map[string]string) *emuDataset {
+	if props == nil {
+		props = map[string]string{}
+	}
+	ds := &emuDataset{
+		name:       name,
+		typ:        typ,
+		creation:   e.now().Unix(),
+		createtxg:  e.nextTxg(),
+		referenced: 24576,
+		props:      props,
+	}
+	ds.guid = e.newGUID(name)
+	if typ == zfs.DatasetVolume {
+		ds.referenced = 12288
+	}
+	e.datasets[name] = ds
+	return ds
+}
+
+// children returns the direct children of a filesystem or volume, sorted by name.
+func (e *Emulator) children(name string) []*emuDataset {
+	var out []*emuDataset
+	for n, ds := range e.datasets {
+		if datasetOf(n) == n && parentOf(n) == name {
+			out = append(out, ds)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+// snapshots returns the snapshots of a filesystem or volume, oldest first.
+func (e *Emulator) snapshots(name string) []*emuDataset {
+	var out []*emuDataset
+	for n, ds := range e.datasets {
+		if ds.typ == zfs.DatasetSnapshot && datasetOf(n) == name {
+			out = append(out, ds)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].createtxg < out[j].createtxg })
+	return out
+}
+
+// descendants returns all datasets below name, including snapshots and bookmarks, but not name itself.
+func (e *Emulator) descendants(name string) []*emuDataset {
+	var out []*emuDataset
+	for n, ds := range e.datasets {
+		if n != name && (strings.HasPrefix(n, name+"/") || strings.HasPrefix(n, name+"@") || strings.HasPrefix(n, name+"#")) {
+			out = append(out, ds)
+		}
+	}
+	sortDatasets(out)
+	return out
+}
+
+// clones returns the datasets cloned from the snapshot name.
+func (e *Emulator) clones(name string) []*emuDataset {
+	var out []*emuDataset
+	for _, ds := range e.datasets {
+		if ds.origin == name {
+			out = append(out, ds)
+		}
+	}
+	sortDatasets(out)
+	return out
+}
+
+// sortDatasets sorts datasets the way `zfs list` does: by name, each dataset followed by its snapshots ordered by
+// creation.
+func sortDatasets(list []*emuDataset) {
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		da, db := datasetOf(a.name), datasetOf(b.name)
+		if da != db {
+			return da < db
+		}
+		if a.name == da || b.name == db {
+			return a.name == da && b.name != db
+		}
+		if a.createtxg != b.createtxg {
+			return a.createtxg < b.createtxg
+		}
+		return a.name > b.name
+	})
+}
+
+// used returns the space used by a dataset and all its descendants.
+func (e *Emulator) used(ds *emuDataset) uint64 {
+	if ds.typ == zfs.DatasetSnapshot {
+		return 0
+	}
+	used := ds.referenced
+	if ds.typ == zfs.DatasetVolume {
+		v, _ := strconv.ParseUint(ds.props["volsize"], 10, 64)
+		used += v
+	}
+	for _, child := range e.children(ds.name) {
+		used += e.used(child)
+	}
+	return used
+}
+
+func (e *Emulator) available(ds *emuDataset) uint64 {
+	pool := e.pools[poolOf(ds.name)]
+	root := e.datasets[pool.name]
+	var avail uint64
+	if u := e.used(root); u < pool.size {
+		avail = pool.size - u
+	}
+	for n := datasetOf(ds.name); n != ""; n = parentOf(n) {
+		quota, _ := strconv.ParseUint(e.datasets[n].props["quota"], 10, 64)
+		if quota == 0 {
+			continue
+		}
+		if u := e.used(e.datasets[n]); u >= quota {
+			avail = 0
+		} else if quota-u < avail {
+			avail = quota - u
+		}
+	}
+	return avail
+}
@@ -0,0 +1,1257 @@
+package zfstest
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+)
+
+func (e *Emulator) zfs(c *emuCmd) error {
+	// Sends and receives take the lock themselves, so that a send can be piped into a receive.
+	switch c.args[0] {
+	case "send":
+		return e.zfsSend(c)
+	case "receive", "recv":
+		return e.zfsReceive(c)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch c.args[0] {
+	case "create":
+		return e.zfsCreate(c)
+	case "snapshot", "snap":
+		return e.zfsSnapshot(c)
+	case "bookmark":
+		return e.zfsBookmark(c)
+	case "clone":
+		return e.zfsClone(c)
+	case "rename":
+		return e.zfsRename(c)
+	case "destroy":
+		return e.zfsDestroy(c)
+	case "rollback":
+		return e.zfsRollback(c)
+	case "set":
+		return e.zfsSet(c)
+	case "inherit":
+		return e.zfsInherit(c)
+	case "get":
+		return e.zfsGet(c)
+	case "hold":
+		return e.zfsHold(c)
+	case "release":
+		return e.zfsRelease(c)
+	case "holds":
+		return e.zfsHolds(c)
+	case "list":
+		return e.zfsList(c)
+	case "mount", "umount", "unmount":
+		return e.zfsMount(c)
+	case "version", "--version":
+		return e.zfsVersion(c)
+	}
+	return usagef("unrecognized command '%s'", c.args[0])
+}
+
+func (e *Emulator) zpool(c *emuCmd) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch c.args[0] {
+	case "create":
+		return e.zpoolCreate(c)
+	case "destroy":
+		return e.zpoolDestroy(c)
+	case "list":
+		return e.zpoolList(c)
+	case "get":
+		return e.zpoolGet(c)
+	}
+	return usagef("unrecognized command '%s'", c.args[0])
+}
+
+func splitList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		out = append(out, strings.Split(v, ",")...)
+	}
+	return out
+}
+
+// printRows prints rows as tab separated lines when scripted, and with a header otherwise.
+func (c *emuCmd) printRows(scripted bool, header []string, rows [][]string) {
+	if !scripted {
+		upper := make([]string, len(header))
+		for i, h := range header {
+			upper[i] = strings.ToUpper(h)
+		}
+		rows = append([][]string{upper}, rows...)
+	}
+	for _, row := range rows {
+		c.printf("%s\n", strings.Join(row, "\t"))
+	}
+}
+
+func (e *Emulator) checkCreate(name string, parents bool) error {
+	if !validName(name) || strings.ContainsAny(name, "@#") {
+		return failf("cannot create '%s': invalid character in name", name)
+	}
+	if _, ok := e.pools[poolOf(name)]; !ok {
+		return failf("cannot create '%s': no such pool '%s'", name, poolOf(name))
+	}
+	if _, ok := e.datasets[name]; ok {
+		return failf("cannot create '%s': dataset already exists", name)
+	}
+	parent := parentOf(name)
+	if parent == "" {
+		return failf("cannot create '%s': missing dataset name", name)
+	}
+	for p := parent; p != ""; p = parentOf(p) {
+		ds, ok := e.datasets[p]
+		if ok {
+			if ds.typ != zfs.DatasetFilesystem {
+				return failf("cannot create '%s': parent is not a filesystem", name)
+			}
+			break
+		}
+		if !parents {
+			return failf("cannot create '%s': parent does not exist", name)
+		}
+	}
+	return nil
+}
+
+// addParents creates the missing parent filesystems of name.
+func (e *Emulator) addParents(name string) {
+	var missing []string
+	for p := parentOf(name); p != ""; p = parentOf(p) {
+		if _, ok := e.datasets[p]; ok {
+			break
+		}
+		missing = append(missing, p)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		e.add(missing[i], zfs.DatasetFilesystem, nil)
+	}
+}
+
+func (e *Emulator) zfsCreate(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "pusV:o:b:")
+	if err != nil {
+		return err
+	}
+	if len(operands) != 1 {
+		return usagef("wrong number of arguments")
+	}
+	name := operands[0]
+
+	typ := zfs.DatasetFilesystem
+	if _, ok := opts['V']; ok {
+		typ = zfs.DatasetVolume
+	}
+	props, err := parseProps(opts['o'])
+	if err != nil {
+		return err
+	}
+	if v, ok := opts['V']; ok {
+		props["volsize"] = v[len(v)-1]
+	}
+	if v, ok := opts['b']; ok {
+		props["volblocksize"] = v[len(v)-1]
+	}
+	if props, err = validateProps(props, typ); err != nil {
+		return failf("cannot create '%s': %s", name, err)
+	}
+	if err := e.checkCreate(name, opts['p'] != nil); err != nil {
+		return err
+	}
+
+	e.addParents(name)
+	e.add(name, typ, props)
+	return nil
+}
+
+func (e *Emulator) zfsSnapshot(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "ro:")
+	if err != nil {
+		return err
+	}
+	if len(operands) == 0 {
+		return usagef("missing snapshot argument")
+	}
+	props, err := parseProps(opts['o'])
+	if err != nil {
+		return err
+	}
+	if props, err = validateProps(props, zfs.DatasetSnapshot); err != nil {
+		return failf("cannot create snapshot '%s': %s", operands[0], err)
+	}
+
+	var names []string
+	for _, snap := range operands {
+		i := strings.IndexByte(snap, '@')
+		if i < 0 || !validName(snap) {
+			return failf("cannot create snapshot '%s': invalid character in name", snap)
+		}
+		ds, err := e.lookup(snap[:i])
+		if err != nil {
+			return failf("cannot open '%s': dataset does not exist\nusage:\n\tsnapshot [-r] [-o property=value] ... <filesystem|volume>@<snap> ...", snap[:i])
+		}
+		names = append(names, snap)
+		if opts['r'] != nil {
+			for _, d := range e.descendants(ds.name) {
+				if d.typ != zfs.DatasetSnapshot {
+					names = append(names, d.name+snap[i:])
+				}
+			}
+		}
+	}
+	for _, name := range names {
+		if _, ok := e.datasets[name]; ok {
+			return failf("cannot create snapshot '%s': dataset already exists", name)
+		}
+	}
+
+	txg := e.nextTxg()
+	for _, name := range names {
+		e.snapshot(name, props).createtxg = txg
+	}
+	return nil
+}
+
+// snapshot creates the snapshot name of an existing dataset.
+func (e *Emulator) snapshot(name string, props map[string]string) *emuDataset {
+	snap := e.add(name, zfs.DatasetSnapshot, copyProps(props))
+	snap.referenced = e.datasets[datasetOf(name)].referenced
+	return snap
+}
+
+func (e *Emulator) zfsBookmark(c *emuCmd) error {
+	_, operands, err := getopt(c.args[1:], "")
+	if err != nil {
+		return err
+	}
+	if len(operands) != 2 {
+		return usagef("wrong number of arguments")
+	}
+	source, err := e.lookup(operands[0])
+	if err != nil {
+		return err
+	}
+	name := operands[1]
+	if strings.HasPrefix(name, "#") {
+		name = datasetOf(source.name) + name
+	}
+	switch {
+	case source.typ == zfs.DatasetBookmark && !e.versionAtLeast(2, 0):
+		return failf("cannot create bookmark '%s': source is not an existing snapshot", name)
+	case source.typ != zfs.DatasetSnapshot && source.typ != zfs.DatasetBookmark:
+		return failf("cannot create bookmark '%s': source is not an existing snapshot or bookmark", name)
+	case !validName(name) || !strings.Contains(name, "#"):
+		return failf("cannot create bookmark '%s': invalid character in name", name)
+	case datasetOf(name) != datasetOf(source.name):
+		return failf("cannot create bookmark '%s': bookmark is in a different filesystem than the source", name)
+	}
+	if _, ok := e.datasets[name]; ok {
+		return failf("cannot create bookmark '%s': bookmark exists", name)
+	}
+	e.datasets[name] = &emuDataset{
+		name:      name,
+		typ:       zfs.DatasetBookmark,
+		creation:  source.creation,
+		createtxg: source.createtxg,
+		guid:      source.guid,
+		props:     map[string]string{},
+	}
+	return nil
+}
+
+func copyProps(props map[string]string) map[string]string {
+	out := make(map[string]string, len(props))
+	for k, v := range props {
+		out[k] = v
+	}
+	return out
+}
+
+func (e *Emulator) zfsClone(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "po:")
+	if err != nil {
+		return err
+	}
+	if len(operands) != 2 {
+		return usagef("wrong number of arguments")
+	}
+	snap, err := e.lookup(operands[0])
+	if err != nil {
+		return err
+	}
+	if snap.typ != zfs.DatasetSnapshot {
+		return failf("cannot create '%s': '%s' is not a snapshot", operands[1], operands[0])
+	}
+	typ := e.datasets[datasetOf(snap.name)].typ
+	props, err := parseProps(opts['o'])
+	if err != nil {
+		return err
+	}
+	if props, err = validateProps(props, typ); err != nil {
+		return failf("cannot create '%s': %s", operands[1], err)
+	}
+	if poolOf(operands[1]) != poolOf(snap.name) {
+		return failf("cannot create '%s': source and target pools differ", operands[1])
+	}
+	if err := e.checkCreate(operands[1], opts['p'] != nil); err != nil {
+		return err
+	}
+
+	e.addParents(operands[1])
+	if v, ok := e.datasets[datasetOf(snap.name)].props["volsize"]; ok {
+		props["volsize"] = v
+	}
+	clone := e.add(operands[1], typ, props)
+	clone.origin = snap.name
+	clone.referenced = snap.referenced
+	return nil
+}
+
+// move renames the dataset from to to, updating the origin of any clones.
+func (e *Emulator) move(from, to string) {
+	ds := e.datasets[from]
+	delete(e.datasets, from)
+	ds.name = to
+	e.datasets[to] = ds
+	for _, other := range e.datasets {
+		if other.origin == from {
+			other.origin = to
+		}
+	}
+}
+
+func (e *Emulator) zfsRename(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "fpru")
+	if err != nil {
+		return err
+	}
+	if len(operands) != 2 {
+		return usagef("wrong number of arguments")
+	}
+	from, to := operands[0], operands[1]
+	ds, err := e.lookup(from)
+	if err != nil && (opts['r'] == nil || !strings.Contains(from, "@")) {
+		return err
+	}
+
+	if strings.Contains(from, "@") {
+		if strings.HasPrefix(to, "@") {
+			to = datasetOf(from) + to
+		}
+		if datasetOf(to) != datasetOf(from) || !validName(to) {
+			return failf("cannot rename to '%s': snapshots must be part of same dataset", to)
+		}
+		snapFrom, snapTo := from[len(datasetOf(from)):], to[len(datasetOf(to)):]
+		renames := map[string]string{}
+		if ds != nil {
+			renames[from] = to
+		}
+		if opts['r'] != nil {
+			for _, d := range e.descendants(datasetOf(from)) {
+				if d.name == datasetOf(d.name)+snapFrom {
+					renames[d.name] = datasetOf(d.name) + snapTo
+				}
+			}
+		}
+		if len(renames) == 0 {
+			return failf("cannot open '%s': dataset does not exist", from)
+		}
+		for _, t := range renames {
+			if _, ok := e.datasets[t]; ok {
+				return failf("cannot rename to '%s': dataset already exists", t)
+			}
+		}
+		for f, t := range renames {
+			e.move(f, t)
+		}
+		return nil
+	}
+
+	if opts['r'] != nil {
+		return usagef("-r is only valid for snapshots")
+	}
+	if parentOf(from) == "" {
+		return failf("cannot rename '%s': operation does not apply to pools", from)
+	}
+	if poolOf(from) != poolOf(to) {
+		return failf("cannot rename to '%s': datasets must be within same pool", to)
+	}
+	if strings.HasPrefix(to, from+"/") {
+		return failf("cannot rename to '%s': New dataset name cannot be a descendant of current dataset name", to)
+	}
+	if err := e.checkCreate(to, opts['p'] != nil); err != nil {
+		return failf("cannot rename to '%s': %s", to, strings.TrimPrefix(err.Error(), fmt.Sprintf("cannot create '%s': ", to)))
+	}
+
+	e.addParents(to)
+	for _, d := range append(e.descendants(from), ds) {
+		e.move(d.name, to+strings.TrimPrefix(d.name, from))
+	}
+	return nil
+}
+
+// dependentClones returns the clones of snapshots in list which are not themselves in list, along with everything
+// depending on those clones.
+func (e *Emulator) dependentClones(list []*emuDataset) []*emuDataset {
+	seen := map[string]bool{}
+	for _, ds := range list {
+		seen[ds.name] = true
+	}
+	var out []*emuDataset
+	for i := 0; i < len(list); i++ {
+		if list[i].typ != zfs.DatasetSnapshot {
+			continue
+		}
+		for _, clone := range e.clones(list[i].name) {
+			if seen[clone.name] {
+				continue
+			}
+			deps := append([]*emuDataset{clone}, e.descendants(clone.name)...)
+			for _, d := range deps {
+				seen[d.name] = true
+			}
+			out = append(out, deps...)
+			list = append(list, deps...)
+		}
+	}
+	sortDatasets(out)
+	return out
+}
+
+func names(list []*emuDataset) string {
+	out := make([]string, len(list))
+	for i, ds := range list {
+		out[i] = ds.name
+	}
+	return strings.Join(out, "\n")
+}
+
+func (e *Emulator) zfsDestroy(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "rRdfnpv")
+	if err != nil {
+		return err
+	}
+	if len(operands) != 1 {
+		return usagef("wrong number of arguments")
+	}
+	name := operands[0]
+	recursive := opts['r'] != nil || opts['R'] != nil
+
+	var targets []*emuDataset
+	if strings.Contains(name, "#") {
+		bookmark, err := e.lookup(name)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, bookmark)
+	} else if i := strings.IndexByte(name, '@'); i >= 0 {
+		ds, err := e.lookup(name[:i])
+		if err != nil {
+			return err
+		}
+		// A comma-separated list of snapshots may be given, missing ones being skipped.
+		for _, snapName := range strings.Split(name[i+1:], ",") {
+			for _, d := range append([]*emuDataset{ds}, e.descendants(ds.name)...) {
+				if d == ds || (opts['r'] != nil && d.typ != zfs.DatasetSnapshot) {
+					if snap, ok := e.datasets[d.name+"@"+snapName]; ok {
+						targets = append(targets, snap)
+					}
+				}
+			}
+		}
+		if len(targets) == 0 {
+			return failf("could not find any snapshots to destroy; check snapshot names.")
+		}
+		if opts['d'] != nil {
+			return e.destroyDeferred(c, targets, opts['R'] != nil, opts['n'] != nil, opts['v'] != nil)
+		}
+	} else {
+		ds, err := e.lookup(name)
+		if err != nil {
+			return err
+		}
+		descendants := e.descendants(name)
+		if parentOf(name) == "" {
+			if !recursive {
+				return failf("cannot destroy '%s': operation does not apply to pools\nuse 'zfs destroy -r %s' to destroy all datasets in the pool\nuse 'zpool destroy %s' to destroy the pool itself", name, name, name)
+			}
+		} else {
+			targets = append(targets, ds)
+		}
+		if len(descendants) > 0 && !recursive {
+			return failf("cannot destroy '%s': filesystem has children\nuse '-r' to destroy the following datasets:\n%s", name, names(descendants))
+		}
+		targets = append(descendants, targets...)
+	}
+
+	if clones := e.dependentClones(targets); len(clones) > 0 {
+		if opts['R'] == nil {
+			kind := "filesystem"
+			if strings.Contains(name, "@") {
+				kind = "snapshot"
+			}
+			return failf("cannot destroy '%s': %s has dependent clones\nuse '-R' to destroy the following datasets:\n%s", name, kind, names(clones))
+		}
+		targets = append(clones, targets...)
+	}
+	for _, ds := range targets {
+		if len(ds.holds) > 0 {
+			return failf("cannot destroy snapshot %s: dataset is busy", ds.name)
+		}
+	}
+
+	e.destroy(c, targets, opts['n'] != nil, opts['v'] != nil)
+	return nil
+}
+
+// destroy removes targets, printing them first if verbose.
+func (e *Emulator) destroy(c *emuCmd, targets []*emuDataset, dryRun, verbose bool) {
+	for _, ds := range targets {
+		if verbose {
+			if dryRun {
+				c.printf("would destroy %s\n", ds.name)
+			} else {
+				c.printf("will destroy %s\n", ds.name)
+			}
+		}
+		if !dryRun {
+			delete(e.datasets, ds.name)
+		}
+	}
+	if !dryRun {
+		e.release()
+	}
+}
+
+// destroyDeferred destroys snapshots which have no dependents or holds and marks the others for deferred destruction.
+func (e *Emulator) destroyDeferred(c *emuCmd, targets []*emuDataset, withClones, dryRun, verbose bool) error {
+	var now []*emuDataset
+	for _, snap := range targets {
+		if len(e.clones(snap.name)) > 0 && !withClones || len(snap.holds) > 0 {
+			if !dryRun {
+				snap.props["defer_destroy"] = "on"
+			}
+			continue
+		}
+		now = append(now, snap)
+	}
+	if withClones {
+		now = append(e.dependentClones(now), now...)
+	}
+	e.destroy(c, now, dryRun, verbose)
+	return nil
+}
+
+// release destroys snapshots marked for deferred destruction which no longer have any dependents or holds.
+func (e *Emulator) release() {
+	for _, ds := range e.datasets {
+		if ds.props["defer_destroy"] == "on" && len(e.clones(ds.name)) == 0 && len(ds.holds) == 0 {
+			delete(e.datasets, ds.name)
+		}
+	}
+}
+
+// holdTargets returns the snapshots named by operands, and with -r the snapshots of the same name of their
+// descendants.
+func (e *Emulator) holdTargets(operands []string, recursive bool, action string) ([]*emuDataset, error) {
+	var targets []*emuDataset
+	for _, name := range operands {
+		i := strings.IndexByte(name, '@')
+		if i < 0 {
+			return nil, failf("'%s' is not a snapshot", name)
+		}
+		snap, ok := e.datasets[name]
+		if !ok {
+			return nil, failf("cannot %s snapshot '%s': dataset does not exist", action, name)
+		}
+		targets = append(targets, snap)
+		if !recursive {
+			continue
+		}
+		for _, d := range e.descendants(name[:i]) {
+			if s, ok := e.datasets[d.name+name[i:]]; ok && d.typ != zfs.DatasetSnapshot {
+				targets = append(targets, s)
+			}
+		}
+	}
+	return targets, nil
+}
+
+func (e *Emulator) zfsHold(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "r")
+	if err != nil {
+		return err
+	}
+	if len(operands) < 2 {
+		return usagef("missing snapshot argument")
+	}
+	tag := operands[0]
+	targets, err := e.holdTargets(operands[1:], opts['r'] != nil, "hold")
+	if err != nil {
+		return err
+	}
+	for _, snap := range targets {
+		if _, ok := snap.holds[tag]; ok {
+			return failf("cannot hold snapshot '%s': tag already exists on this dataset", snap.name)
+		}
+	}
+	for _, snap := range targets {
+		if snap.holds == nil {
+			snap.holds = map[string]time.Time{}
+		}
+		snap.holds[tag] = e.now()
+	}
+	return nil
+}
+
+func (e *Emulator) zfsRelease(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "r")
+	if err != nil {
+		return err
+	}
+	if len(operands) < 2 {
+		return usagef("missing snapshot argument")
+	}
+	tag := operands[0]
+	targets, err := e.holdTargets(operands[1:], opts['r'] != nil, "release hold from")
+	if err != nil {
+		return err
+	}
+	for _, snap := range targets {
+		if _, ok := snap.holds[tag]; !ok {
+			return failf("cannot release hold from snapshot '%s': no such tag on this dataset", snap.name)
+		}
+	}
+	for _, snap := range targets {
+		delete(snap.holds, tag)
+	}
+	e.release()
+	return nil
+}
+
+func (e *Emulator) zfsHolds(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "rHp")
+	if err != nil {
+		return err
+	}
+	if len(operands) == 0 {
+		return usagef("missing snapshot argument")
+	}
+	targets, err := e.holdTargets(operands, opts['r'] != nil, "get holds of")
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, snap := range targets {
+		tags := make([]string, 0, len(snap.holds))
+		for tag := range snap.holds {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			timestamp := snap.holds[tag].Format("Mon Jan _2 15:04 2006")
+			if opts['p'] != nil {
+				timestamp = strconv.FormatInt(snap.holds[tag].Unix(), 10)
+			}
+			rows = append(rows, []string{snap.name, tag, timestamp})
+		}
+	}
+	c.printRows(opts['H'] != nil, []string{"NAME", "TAG", "TIMESTAMP"}, rows)
+	return nil
+}
+
+func (e *Emulator) zfsRollback(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "rRf")
+	if err != nil {
+		return err
+	}
+	if len(operands) != 1 {
+		return usagef("wrong number of arguments")
+	}
+	snap, err := e.lookup(operands[0])
+	if err != nil {
+		return err
+	}
+	if snap.typ != zfs.DatasetSnapshot {
+		return usagef("'%s' is not a snapshot", snap.name)
+	}
+
+	var newer []*emuDataset
+	for _, s := range e.snapshots(datasetOf(snap.name)) {
+		if s.createtxg > snap.createtxg {
+			newer = append(newer, s)
+		}
+	}
+	if len(newer) > 0 {
+		if opts['r'] == nil && opts['R'] == nil {
+			return failf("cannot rollback to '%s': more recent snapshots or bookmarks exist\nuse '-r' to force deletion of the following snapshots and bookmarks:\n%s", snap.name, names(newer))
+		}
+		clones := e.dependentClones(newer)
+		if len(clones) > 0 && opts['R'] == nil {
+			return failf("cannot rollback to '%s': clones of previous snapshots exist\nuse '-R' to force deletion of the following clones and dependents:\n%s", snap.name, names(clones))
+		}
+		e.destroy(c, append(clones, newer...), false, false)
+	}
+
+	e.datasets[datasetOf(snap.name)].referenced = snap.referenced
+	return nil
+}
+
+func (e *Emulator) zfsSet(c *emuCmd) error {
+	var assignments, targets []string
+	for _, arg := range c.args[1:] {
+		if len(targets) == 0 && strings.Contains(arg, "=") {
+			assignments = append(assignments, arg)
+		} else {
+			targets = append(targets, arg)
+		}
+	}
+	if len(assignments) == 0 || len(targets) == 0 {
+		return usagef("missing arguments")
+	}
+	props, err := parseProps(assignments)
+	if err != nil {
+		return err
+	}
+	for _, name := range targets {
+		ds, err := e.lookup(name)
+		if err != nil {
+			return err
+		}
+		valid, err := validateProps(props, ds.typ)
+		if err != nil {
+			return failf("cannot set property for '%s': %s", name, err)
+		}
+		for k, v := range valid {
+			ds.props[k] = v
+		}
+	}
+	return nil
+}
+
+func (e *Emulator) zfsInherit(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "rS")
+	if err != nil {
+		return err
+	}
+	if len(operands) < 2 {
+		return usagef("missing arguments")
+	}
+	prop, ok := canonicalProp(operands[0])
+	if !ok {
+		return usagef("invalid property '%s'", operands[0])
+	}
+	if info := emuProps[prop]; !isUserProp(prop) {
+		if info.readonly {
+			return failf("'%s' property is read-only", prop)
+		}
+		if !info.inherit {
+			return failf("'%s' property cannot be inherited", prop)
+		}
+	}
+	for _, name := range operands[1:] {
+		ds, err := e.lookup(name)
+		if err != nil {
+			return err
+		}
+		delete(ds.props, prop)
+		if opts['r'] != nil {
+			for _, d := range e.descendants(ds.name) {
+				delete(d.props, prop)
+			}
+		}
+	}
+	return nil
+}
+
+// typeFilter parses the argument of a -t option.
+func typeFilter(values []string, def int) (int, error) {
+	if values == nil {
+		return def, nil
+	}
+	mask := 0
+	for _, t := range splitList(values) {
+		switch t {
+		case "all":
+			mask |= forAll | forBookmark
+		case "filesystem", "fs":
+			mask |= forFilesystem
+		case "volume", "vol":
+			mask |= forVolume
+		case "snapshot", "snap":
+			mask |= forSnapshot
+		case "bookmark":
+			mask |= forBookmark
+		default:
+			return 0, usagef("invalid type '%s'", t)
+		}
+	}
+	return mask, nil
+}
+
+// depthLimit parses the -r and -d options, returning -1 for unlimited recursion and 0 for none.
+func depthLimit(opts map[byte][]string) (int, error) {
+	if d, ok := opts['d']; ok {
+		depth, err := strconv.Atoi(d[len(d)-1])
+		if err != nil || depth < 0 {
+			return 0, usagef("invalid depth '%s'", d[len(d)-1])
+		}
+		return depth, nil
+	}
+	if opts['r'] != nil {
+		return -1, nil
+	}
+	return 0, nil
+}
+
+// selectDatasets returns the datasets matching the operands of a `zfs list` or `zfs get` command.
+func (e *Emulator) selectDatasets(operands []string, types, depth int, explicitTypes bool) ([]*emuDataset, error) {
+	if len(operands) == 0 {
+		depth = -1
+		for name := range e.pools {
+			operands = append(operands, name)
+		}
+	}
+
+	seen := map[string]bool{}
+	var out []*emuDataset
+	include := func(ds *emuDataset) {
+		if !seen[ds.name] && types&typeMask(ds.typ) != 0 {
+			seen[ds.name] = true
+			out = append(out, ds)
+		}
+	}
+	for _, name := range operands {
+		ds, err := e.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if depth == 0 {
+			if explicitTypes && types&typeMask(ds.typ) == 0 && types&(forSnapshot|forBookmark) != 0 {
+				// Listing snapshots or bookmarks of a filesystem implies a depth of one.
+				for _, d := range e.descendants(ds.name) {
+					if datasetOf(d.name) == ds.name {
+						include(d)
+					}
+				}
+			} else if !explicitTypes || types&typeMask(ds.typ) != 0 {
+				seen[ds.name] = true
+				out = append(out, ds)
+			}
+			continue
+		}
+		include(ds)
+		for _, d := range e.descendants(ds.name) {
+			if depth < 0 || depthOf(d.name)-depthOf(ds.name) <= depth {
+				include(d)
+			}
+		}
+	}
+	sortDatasets(out)
+	return out, nil
+}
+
+func (e *Emulator) zfsList(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "rHpjd:o:t:s:S:")
+	if err != nil {
+		return err
+	}
+	if err := e.checkJSON(opts); err != nil {
+		return err
+	}
+	fields := []string{"name", "used", "available", "referenced", "mountpoint"}
+	if opts['o'] != nil {
+		fields = splitList(opts['o'])
+	}
+	for _, f := range fields {
+		if _, ok := canonicalProp(f); !ok {
+			return usagef("invalid property '%s'", f)
+		}
+	}
+	types, err := typeFilter(opts['t'], forDatasets)
+	if err != nil {
+		return err
+	}
+	depth, err := depthLimit(opts)
+	if err != nil {
+		return err
+	}
+	list, err := e.selectDatasets(operands, types, depth, opts['t'] != nil)
+	if err != nil {
+		return err
+	}
+
+	if opts['j'] != nil {
+		datasets := &jsonObject{}
+		for _, ds := range list {
+			obj, props := e.jsonDataset(ds)
+			for _, f := range fields {
+				if f == "name" {
+					continue
+				}
+				name, _ := canonicalProp(f)
+				v, source := e.property(ds, name)
+				props.set(name, jsonProperty(display(name, v, opts['p'] != nil), source))
+			}
+			datasets.set(ds.name, obj)
+		}
+		return c.printJSON("zfs list", "datasets", datasets)
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, ds := range list {
+		row := make([]string, len(fields))
+		for i, f := range fields {
+			v, _ := e.property(ds, f)
+			row[i] = display(f, v, opts['p'] != nil)
+		}
+		rows = append(rows, row)
+	}
+	c.printRows(opts['H'] != nil, fields, rows)
+	return nil
+}
+
+// sourceKind returns the kind of source used by the -s option of `zfs get`.
+func sourceKind(source string) string {
+	switch {
+	case source == "-":
+		return "none"
+	case strings.HasPrefix(source, "inherited"):
+		return "inherited"
+	}
+	return source
+}
+
+func (e *Emulator) zfsGet(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "rHpjd:o:s:t:")
+	if err != nil {
+		return err
+	}
+	if err := e.checkJSON(opts); err != nil {
+		return err
+	}
+	if len(operands) == 0 {
+		return usagef("missing property argument")
+	}
+	fields := []string{"name", "property", "value", "source"}
+	if opts['o'] != nil {
+		fields = splitList(opts['o'])
+	}
+	sources := map[string]bool{}
+	for _, s := range splitList(opts['s']) {
+		sources[s] = true
+	}
+
+	var props []string
+	all := operands[0] == "all"
+	for _, p := range strings.Split(operands[0], ",") {
+		name, ok := canonicalProp(p)
+		if !ok && !all {
+			return usagef("bad property list: invalid property '%s'", p)
+		}
+		props = append(props, name)
+	}
+	types, err := typeFilter(opts['t'], forAll|forBookmark)
+	if err != nil {
+		return err
+	}
+	depth, err := depthLimit(opts)
+	if err != nil {
+		return err
+	}
+	list, err := e.selectDatasets(operands[1:], types, depth, false)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	datasets := &jsonObject{}
+	for _, ds := range list {
+		dsProps := props
+		if all {
+			dsProps = e.allProps(ds)
+		}
+		obj, jsonProps := e.jsonDataset(ds)
+		for _, p := range dsProps {
+			value, source := e.property(ds, p)
+			if len(sources) > 0 && !sources[sourceKind(source)] {
+				continue
+			}
+			if opts['j'] != nil {
+				prop := jsonProperty(display(p, value, opts['p'] != nil), source)
+				for _, f := range fields {
+					if f == "received" {
+						prop.set("received", "-")
+					}
+				}
+				jsonProps.set(p, prop)
+				continue
+			}
+			row := make([]string, len(fields))
+			for i, f := range fields {
+				switch f {
+				case "name":
+					row[i] = ds.name
+				case "property":
+					row[i] = p
+				case "value":
+					row[i] = display(p, value, opts['p'] != nil)
+				case "received":
+					row[i] = "-"
+				case "source":
+					row[i] = source
+				default:
+					return usagef("invalid field '%s'", f)
+				}
+			}
+			rows = append(rows, row)
+		}
+		datasets.set(ds.name, obj)
+	}
+	if opts['j'] != nil {
+		return c.printJSON("zfs get", "datasets", datasets)
+	}
+	c.printRows(opts['H'] != nil, fields, rows)
+	return nil
+}
+
+// allProps returns the properties applying to ds, as listed by `zfs get all`.
+func (e *Emulator) allProps(ds *emuDataset) []string {
+	var out []string
+	for name, info := range emuProps {
+		if info.types&typeMask(ds.typ) != 0 {
+			out = append(out, name)
+		}
+	}
+	user := map[string]bool{}
+	for n := ds.name; n != ""; n = inheritParent(n) {
+		for name := range e.datasets[n].props {
+			if isUserProp(name) {
+				user[name] = true
+			}
+		}
+	}
+	for name := range user {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (e *Emulator) zfsMount(c *emuCmd) error {
+	_, operands, err := getopt(c.args[1:], "aflOo:")
+	if err != nil {
+		return err
+	}
+	for _, name := range operands {
+		ds, err := e.lookup(name)
+		if err != nil {
+			return err
+		}
+		if ds.typ != zfs.DatasetFilesystem {
+			return failf("cannot %s '%s': not a filesystem", c.args[0], name)
+		}
+	}
+	return nil
+}
+
+// vdevSize returns the size contributed by a vdev argument of `zpool create`.
+func vdevSize(vdev string) uint64 {
+	switch strings.TrimRight(vdev, "0123456789") {
+	case "mirror", "raidz", "draid", "log", "cache", "spare", "special", "dedup":
+		return 0
+	}
+	if fi, err := os.Stat(vdev); err == nil && fi.Mode().IsRegular() && fi.Size() > 0 {
+		return uint64(fi.Size())
+	}
+	return 1 << 30
+}
+
+func (e *Emulator) zpoolCreate(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "fndo:O:m:R:t:")
+	if err != nil {
+		return err
+	}
+	if len(operands) < 2 {
+		return usagef("missing vdev specification")
+	}
+	name := operands[0]
+	if !validName(name) || strings.ContainsAny(name, "/@#") {
+		return failf("cannot create '%s': invalid character in pool name", name)
+	}
+	if _, ok := e.pools[name]; ok {
+		return failf("cannot create '%s': pool already exists", name)
+	}
+	poolProps, err := parseProps(opts['o'])
+	if err != nil {
+		return err
+	}
+	fsProps, err := parseProps(opts['O'])
+	if err != nil {
+		return err
+	}
+	if m, ok := opts['m']; ok {
+		fsProps["mountpoint"] = m[len(m)-1]
+	}
+	if fsProps, err = validateProps(fsProps, zfs.DatasetFilesystem); err != nil {
+		return failf("cannot create '%s': %s", name, err)
+	}
+	if opts['n'] != nil {
+		return nil
+	}
+
+	pool := &emuPool{name: name, props: poolProps}
+	for _, vdev := range operands[1:] {
+		pool.size += vdevSize(vdev)
+	}
+	e.pools[name] = pool
+	e.add(name, zfs.DatasetFilesystem, fsProps)
+	return nil
+}
+
+func (e *Emulator) zpoolDestroy(c *emuCmd) error {
+	_, operands, err := getopt(c.args[1:], "f")
+	if err != nil {
+		return err
+	}
+	if len(operands) != 1 {
+		return usagef("wrong number of arguments")
+	}
+	name := operands[0]
+	if _, ok := e.pools[name]; !ok {
+		return failf("cannot open '%s': no such pool", name)
+	}
+	for n := range e.datasets {
+		if poolOf(n) == name {
+			delete(e.datasets, n)
+		}
+	}
+	delete(e.pools, name)
+	return nil
+}
+
+// selectPools returns the pools named by operands, or all pools, sorted by name.
+func (e *Emulator) selectPools(operands []string) ([]*emuPool, error) {
+	if len(operands) == 0 {
+		for name := range e.pools {
+			operands = append(operands, name)
+		}
+		sort.Strings(operands)
+	}
+	pools := make([]*emuPool, 0, len(operands))
+	for _, name := range operands {
+		p, ok := e.pools[name]
+		if !ok {
+			return nil, failf("cannot open '%s': no such pool", name)
+		}
+		pools = append(pools, p)
+	}
+	return pools, nil
+}
+
+func (e *Emulator) zpoolList(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "gHLpPvjo:T:")
+	if err != nil {
+		return err
+	}
+	if err := e.checkJSON(opts); err != nil {
+		return err
+	}
+	fields := []string{"name", "size", "allocated", "free", "capacity", "health"}
+	if opts['o'] != nil {
+		fields = splitList(opts['o'])
+	}
+	for _, f := range fields {
+		if !emuPoolProps[f] {
+			return usagef("invalid property '%s'", f)
+		}
+	}
+	pools, err := e.selectPools(operands)
+	if err != nil {
+		return err
+	}
+	if opts['j'] != nil {
+		objects := &jsonObject{}
+		for _, p := range pools {
+			obj, props := e.jsonPool(p)
+			for _, f := range fields {
+				if f != "name" {
+					props.set(f, jsonProperty(e.poolProperty(p, f)))
+				}
+			}
+			objects.set(p.name, obj)
+		}
+		return c.printJSON("zpool list", "pools", objects)
+	}
+
+	rows := make([][]string, 0, len(pools))
+	for _, p := range pools {
+		row := make([]string, len(fields))
+		for i, f := range fields {
+			row[i], _ = e.poolProperty(p, f)
+		}
+		rows = append(rows, row)
+	}
+	c.printRows(opts['H'] != nil, fields, rows)
+	return nil
+}
+
+func (e *Emulator) zpoolGet(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "Hpjo:")
+	if err != nil {
+		return err
+	}
+	if err := e.checkJSON(opts); err != nil {
+		return err
+	}
+	if len(operands) == 0 {
+		return usagef("missing property argument")
+	}
+	props := strings.Split(operands[0], ",")
+	if operands[0] == "all" {
+		props = props[:0]
+		for p := range emuPoolProps {
+			props = append(props, p)
+		}
+		sort.Strings(props)
+	}
+	for _, p := range props {
+		if !emuPoolProps[p] {
+			return usagef("bad property list: invalid property '%s'", p)
+		}
+	}
+	pools, err := e.selectPools(operands[1:])
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	objects := &jsonObject{}
+	for _, pool := range pools {
+		obj, jsonProps := e.jsonPool(pool)
+		for _, p := range props {
+			value, source := e.poolProperty(pool, p)
+			rows = append(rows, []string{pool.name, p, value, source})
+			jsonProps.set(p, jsonProperty(value, source))
+		}
+		objects.set(pool.name, obj)
+	}
+	if opts['j'] != nil {
+		return c.printJSON("zpool get", "pools", objects)
+	}
+	c.printRows(opts['H'] != nil, []string{"name", "property", "value", "source"}, rows)
+	return nil
+}
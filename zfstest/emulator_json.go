@@ -0,0 +1,169 @@
+package zfstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+)
+
+// DefaultVersion is the OpenZFS version emulated when Emulator.Version is empty.
+const DefaultVersion = "2.3.0"
+
+func (e *Emulator) version() string {
+	if e.Version != "" {
+		return e.Version
+	}
+	return DefaultVersion
+}
+
+// versionAtLeast reports whether the emulated version is major.minor or later.
+func (e *Emulator) versionAtLeast(major, minor int) bool {
+	parts := strings.SplitN(e.version(), ".", 3)
+	maj, _ := strconv.Atoi(parts[0])
+	var min int
+	if len(parts) > 1 {
+		min, _ = strconv.Atoi(parts[1])
+	}
+	return maj > major || maj == major && min >= minor
+}
+
+// checkJSON rejects -j before OpenZFS 2.3, as getopt would for an unknown option.
+func (e *Emulator) checkJSON(opts map[byte][]string) error {
+	if opts['j'] != nil && !e.versionAtLeast(2, 3) {
+		return usagef("invalid option 'j'")
+	}
+	return nil
+}
+
+func (e *Emulator) zfsVersion(c *emuCmd) error {
+	opts, _, err := getopt(c.args[1:], "j")
+	if err != nil {
+		return err
+	}
+	if err := e.checkJSON(opts); err != nil {
+		return err
+	}
+	userland, kernel := "zfs-"+e.version()+"-1", "zfs-kmod-"+e.version()+"-1"
+	if opts['j'] != nil {
+		versions := &jsonObject{}
+		versions.set("userland", userland)
+		versions.set("kernel", kernel)
+		return c.printJSON("zfs version", "zfs_version", versions)
+	}
+	c.printf("%s\n%s\n", userland, kernel)
+	return nil
+}
+
+// jsonObject is a JSON object which keeps its members in insertion order, as the ZFS tools print them.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *jsonObject) set(key string, value interface{}) {
+	if o.values == nil {
+		o.values = map[string]interface{}{}
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// printJSON prints the output of command run with -j, with value stored in member.
+func (c *emuCmd) printJSON(command, member string, value interface{}) error {
+	version := &jsonObject{}
+	version.set("command", command)
+	version.set("vers_major", 0)
+	version.set("vers_minor", 1)
+	output := &jsonObject{}
+	output.set("output_version", version)
+	output.set(member, value)
+
+	data, err := json.MarshalIndent(output, "", "    ")
+	if err != nil {
+		return err
+	}
+	c.printf("%s\n", data)
+	return nil
+}
+
+// jsonProperty returns a property as printed with -j.
+func jsonProperty(value, source string) *jsonObject {
+	src := &jsonObject{}
+	switch {
+	case source == "-":
+		src.set("type", "NONE")
+		src.set("data", "-")
+	case strings.HasPrefix(source, "inherited from "):
+		src.set("type", "INHERITED")
+		src.set("data", strings.TrimPrefix(source, "inherited from "))
+	default:
+		src.set("type", strings.ToUpper(source))
+		src.set("data", "-")
+	}
+	prop := &jsonObject{}
+	prop.set("value", value)
+	prop.set("source", src)
+	return prop
+}
+
+// jsonDataset returns the description of ds printed with -j, properties are added to its "properties" member.
+func (e *Emulator) jsonDataset(ds *emuDataset) (*jsonObject, *jsonObject) {
+	obj := &jsonObject{}
+	obj.set("name", ds.name)
+	obj.set("type", strings.ToUpper(ds.typ))
+	obj.set("pool", poolOf(ds.name))
+	obj.set("createtxg", strconv.FormatUint(ds.createtxg, 10))
+	if ds.typ == zfs.DatasetSnapshot {
+		obj.set("dataset", datasetOf(ds.name))
+		obj.set("snapshot_name", ds.name[len(datasetOf(ds.name)):])
+	}
+	props := &jsonObject{}
+	obj.set("properties", props)
+	return obj, props
+}
+
+// jsonPool returns the description of p printed with -j, properties are added to its "properties" member.
+func (e *Emulator) jsonPool(p *emuPool) (*jsonObject, *jsonObject) {
+	obj := &jsonObject{}
+	obj.set("name", p.name)
+	obj.set("type", "POOL")
+	health, _ := e.poolProperty(p, "health")
+	obj.set("state", health)
+	guid, _ := e.poolProperty(p, "guid")
+	obj.set("pool_guid", guid)
+	obj.set("txg", strconv.FormatUint(e.txg, 10))
+	obj.set("spa_version", "5000")
+	obj.set("zpl_version", "5")
+	props := &jsonObject{}
+	obj.set("properties", props)
+	return obj, props
+}
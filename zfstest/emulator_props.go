@@ -0,0 +1,414 @@
+package zfstest
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+)
+
+// Dataset types a property applies to.
+const (
+	forFilesystem = 1 << iota
+	forVolume
+	forSnapshot
+	forBookmark
+
+	forDatasets = forFilesystem | forVolume
+	forAll      = forDatasets | forSnapshot
+)
+
+// emuProp describes a native ZFS property known to the Emulator.
+type emuProp struct {
+	types    int
+	readonly bool
+	inherit  bool
+	def      string
+	// valid normalizes a value being set, returning an error message for invalid values.
+	valid func(string) (string, string)
+}
+
+func oneOf(values ...string) func(string) (string, string) {
+	return func(v string) (string, string) {
+		for _, value := range values {
+			if v == value {
+				return v, ""
+			}
+		}
+		return "", fmt.Sprintf("must be one of '%s'", strings.Join(values, " | "))
+	}
+}
+
+func validSize(v string) (string, string) {
+	n, err := parseSize(v)
+	if err != nil {
+		return "", err.Error()
+	}
+	return strconv.FormatUint(n, 10), ""
+}
+
+func validRecordsize(v string) (string, string) {
+	n, err := parseSize(v)
+	if err != nil {
+		return "", err.Error()
+	}
+	if n < 512 || n > 16<<20 || n&(n-1) != 0 {
+		return "", "must be power of 2 from 512B to 16M"
+	}
+	return strconv.FormatUint(n, 10), ""
+}
+
+func validCompression(v string) (string, string) {
+	switch v {
+	case "on", "off", "lzjb", "gzip", "zle", "lz4", "zstd", "zstd-fast":
+		return v, ""
+	}
+	for _, alg := range []struct {
+		prefix   string
+		min, max int
+	}{{"gzip-", 1, 9}, {"zstd-fast-", 1, 1000}, {"zstd-", 1, 19}} {
+		if !strings.HasPrefix(v, alg.prefix) {
+			continue
+		}
+		if level, err := strconv.Atoi(v[len(alg.prefix):]); err == nil && level >= alg.min && level <= alg.max {
+			return v, ""
+		}
+		break
+	}
+	return "", "must be one of 'on | off | lzjb | gzip | gzip-[1-9] | zle | lz4 | zstd | zstd-[1-19] | zstd-fast | zstd-fast-[1-10,20,30,...,100,500,1000]'"
+}
+
+func validMountpoint(v string) (string, string) {
+	if v == "none" || v == "legacy" {
+		return v, ""
+	}
+	if !strings.HasPrefix(v, "/") {
+		return "", "must be an absolute path, 'none', or 'legacy'"
+	}
+	return path.Clean(v), ""
+}
+
+var onOff = oneOf("on", "off")
+
+var emuProps = map[string]emuProp{
+	"name":                 {types: forAll | forBookmark, readonly: true},
+	"type":                 {types: forAll | forBookmark, readonly: true},
+	"creation":             {types: forAll | forBookmark, readonly: true},
+	"createtxg":            {types: forAll | forBookmark, readonly: true},
+	"guid":                 {types: forAll | forBookmark, readonly: true},
+	"used":                 {types: forAll, readonly: true},
+	"available":            {types: forDatasets, readonly: true},
+	"referenced":           {types: forAll, readonly: true},
+	"written":              {types: forAll, readonly: true},
+	"logicalused":          {types: forDatasets, readonly: true},
+	"logicalreferenced":    {types: forAll, readonly: true},
+	"usedbydataset":        {types: forDatasets, readonly: true},
+	"usedbysnapshots":      {types: forDatasets, readonly: true},
+	"usedbychildren":       {types: forDatasets, readonly: true},
+	"compressratio":        {types: forAll, readonly: true},
+	"origin":               {types: forDatasets, readonly: true},
+	"clones":               {types: forSnapshot, readonly: true},
+	"mounted":              {types: forFilesystem, readonly: true},
+	"defer_destroy":        {types: forSnapshot, readonly: true},
+	"userrefs":             {types: forSnapshot, readonly: true},
+	"receive_resume_token": {types: forDatasets, readonly: true},
+	"volsize":              {types: forVolume, valid: validSize},
+	"volblocksize":         {types: forVolume, def: "16384", valid: validRecordsize},
+	"quota":                {types: forFilesystem, def: "0", valid: validSize},
+	"refquota":             {types: forFilesystem, def: "0", valid: validSize},
+	"reservation":          {types: forDatasets, def: "0", valid: validSize},
+	"refreservation":       {types: forDatasets, def: "0", valid: validSize},
+	"mountpoint":           {types: forFilesystem, inherit: true, valid: validMountpoint},
+	"canmount":             {types: forFilesystem, def: "on", valid: oneOf("on", "off", "noauto")},
+	"compression":          {types: forAll, inherit: true, def: "on", valid: validCompression},
+	"atime":                {types: forFilesystem | forSnapshot, inherit: true, def: "on", valid: onOff},
+	"relatime":             {types: forFilesystem | forSnapshot, inherit: true, def: "on", valid: onOff},
+	"readonly":             {types: forDatasets, inherit: true, def: "off", valid: onOff},
+	"exec":                 {types: forFilesystem | forSnapshot, inherit: true, def: "on", valid: onOff},
+	"setuid":               {types: forFilesystem | forSnapshot, inherit: true, def: "on", valid: onOff},
+	"devices":              {types: forFilesystem | forSnapshot, inherit: true, def: "on", valid: onOff},
+	"sync":                 {types: forDatasets, inherit: true, def: "standard", valid: oneOf("standard", "always", "disabled")},
+	"recordsize":           {types: forFilesystem, inherit: true, def: "131072", valid: validRecordsize},
+	"copies":               {types: forDatasets, inherit: true, def: "1", valid: oneOf("1", "2", "3")},
+	"xattr":                {types: forFilesystem | forSnapshot, inherit: true, def: "on", valid: oneOf("on", "off", "sa", "dir")},
+	"snapdir":              {types: forFilesystem, inherit: true, def: "hidden", valid: oneOf("hidden", "visible")},
+	"checksum":             {types: forDatasets, inherit: true, def: "on", valid: oneOf("on", "off", "fletcher2", "fletcher4", "sha256", "sha512", "skein", "edonr", "blake3", "noparity")},
+	"dedup":                {types: forDatasets, inherit: true, def: "off", valid: oneOf("on", "off", "verify", "sha256", "sha256,verify", "sha512", "sha512,verify", "skein", "skein,verify", "blake3", "blake3,verify")},
+	"logbias":              {types: forDatasets, inherit: true, def: "latency", valid: oneOf("latency", "throughput")},
+	"primarycache":         {types: forAll, inherit: true, def: "all", valid: oneOf("all", "none", "metadata")},
+	"secondarycache":       {types: forAll, inherit: true, def: "all", valid: oneOf("all", "none", "metadata")},
+	"acltype":              {types: forFilesystem | forSnapshot, inherit: true, def: "off", valid: oneOf("off", "nfsv4", "posix", "noacl", "posixacl")},
+	"encryption":           {types: forAll, readonly: true, def: "off"},
+}
+
+// Aliases accepted for native property names.
+var emuPropAliases = map[string]string{
+	"avail":       "available",
+	"refer":       "referenced",
+	"compress":    "compression",
+	"recsize":     "recordsize",
+	"volblock":    "volblocksize",
+	"reserv":      "reservation",
+	"refreserv":   "refreservation",
+	"lused":       "logicalused",
+	"lrefer":      "logicalreferenced",
+	"usedsnap":    "usedbysnapshots",
+	"usedds":      "usedbydataset",
+	"usedchild":   "usedbychildren",
+	"ratio":       "compressratio",
+	"defer_destr": "defer_destroy",
+}
+
+func isUserProp(name string) bool {
+	return strings.Contains(name, ":")
+}
+
+// canonicalProp returns the canonical name of a property, and whether it is known.
+func canonicalProp(name string) (string, bool) {
+	if alias, ok := emuPropAliases[name]; ok {
+		name = alias
+	}
+	if isUserProp(name) {
+		return name, true
+	}
+	_, ok := emuProps[name]
+	return name, ok
+}
+
+func typeMask(typ string) int {
+	switch typ {
+	case zfs.DatasetFilesystem:
+		return forFilesystem
+	case zfs.DatasetVolume:
+		return forVolume
+	case zfs.DatasetSnapshot:
+		return forSnapshot
+	case zfs.DatasetBookmark:
+		return forBookmark
+	}
+	return 0
+}
+
+// inheritParent returns the dataset properties of name are inherited from.
+func inheritParent(name string) string {
+	if ds := datasetOf(name); ds != name {
+		return ds
+	}
+	return parentOf(name)
+}
+
+// inherited resolves a property which is inherited along the dataset hierarchy.
+func (e *Emulator) inherited(ds *emuDataset, prop string) (string, string, bool) {
+	for n := ds.name; n != ""; n = inheritParent(n) {
+		v, ok := e.datasets[n].props[prop]
+		if !ok {
+			continue
+		}
+		if n == ds.name {
+			return v, "local", true
+		}
+		return v, "inherited from " + n, true
+	}
+	return "", "", false
+}
+
+// property returns the value and source of a property of ds. The value is "-" for properties which do not apply to
+// ds.
+func (e *Emulator) property(ds *emuDataset, prop string) (string, string) {
+	prop, _ = canonicalProp(prop)
+	if isUserProp(prop) {
+		if v, source, ok := e.inherited(ds, prop); ok {
+			return v, source
+		}
+		return "-", "-"
+	}
+
+	info := emuProps[prop]
+	if info.types&typeMask(ds.typ) == 0 {
+		return "-", "-"
+	}
+
+	u := func(v uint64) (string, string) {
+		return strconv.FormatUint(v, 10), "-"
+	}
+	switch prop {
+	case "name":
+		return ds.name, "-"
+	case "type":
+		return ds.typ, "-"
+	case "creation":
+		return strconv.FormatInt(ds.creation, 10), "-"
+	case "createtxg":
+		return u(ds.createtxg)
+	case "guid":
+		return u(ds.guid)
+	case "used", "logicalused":
+		return u(e.used(ds))
+	case "available":
+		return u(e.available(ds))
+	case "referenced", "logicalreferenced", "usedbydataset":
+		return u(ds.referenced)
+	case "written":
+		if ds.typ != zfs.DatasetSnapshot && len(e.snapshots(ds.name)) > 0 {
+			return u(0)
+		}
+		return u(ds.referenced)
+	case "usedbysnapshots":
+		return u(0)
+	case "usedbychildren":
+		var used uint64
+		for _, child := range e.children(ds.name) {
+			used += e.used(child)
+		}
+		return u(used)
+	case "compressratio":
+		return "1.00x", "-"
+	case "origin":
+		if ds.origin == "" {
+			return "-", "-"
+		}
+		return ds.origin, "-"
+	case "clones":
+		var names []string
+		for _, clone := range e.clones(ds.name) {
+			names = append(names, clone.name)
+		}
+		return strings.Join(names, ","), "-"
+	case "mounted":
+		return "yes", "-"
+	case "defer_destroy":
+		if v, ok := ds.props[prop]; ok {
+			return v, "-"
+		}
+		return "off", "-"
+	case "userrefs":
+		return strconv.Itoa(len(ds.holds)), "-"
+	case "receive_resume_token":
+		if ds.partial != nil {
+			return ds.partial.token(), "-"
+		}
+		return "-", "-"
+	case "mountpoint":
+		return e.mountpoint(ds)
+	}
+
+	if info.inherit {
+		if v, source, ok := e.inherited(ds, prop); ok {
+			return v, source
+		}
+		return info.def, "default"
+	}
+	if v, ok := ds.props[prop]; ok {
+		return v, "local"
+	}
+	if info.readonly {
+		return info.def, "-"
+	}
+	return info.def, "default"
+}
+
+func (e *Emulator) mountpoint(ds *emuDataset) (string, string) {
+	for n := ds.name; n != ""; n = parentOf(n) {
+		v, ok := e.datasets[n].props["mountpoint"]
+		if !ok {
+			continue
+		}
+		source := "inherited from " + n
+		if n == ds.name {
+			source = "local"
+		}
+		if v == "none" || v == "legacy" {
+			return v, source
+		}
+		return path.Join(v, strings.TrimPrefix(ds.name, n)), source
+	}
+	return "/" + ds.name, "default"
+}
+
+// display formats a property value as printed by the ZFS tools. Values are always printed exactly, parsable only
+// affects how unset limits are shown.
+func display(prop, value string, parsable bool) string {
+	switch prop {
+	case "quota", "refquota", "reservation", "refreservation":
+		if value == "0" && !parsable {
+			return "none"
+		}
+	}
+	return value
+}
+
+// validateProp checks that value may be assigned to prop on a dataset of type typ and returns its normalized form.
+func validateProp(prop, value, typ string) (string, string, error) {
+	name, ok := canonicalProp(prop)
+	if !ok {
+		return "", "", fmt.Errorf("invalid property '%s'", prop)
+	}
+	if isUserProp(name) {
+		if len(value) > 8191 {
+			return "", "", fmt.Errorf("property value '%s' is too long", name)
+		}
+		return name, value, nil
+	}
+	info := emuProps[name]
+	if info.readonly || info.valid == nil {
+		return "", "", fmt.Errorf("'%s' is readonly", name)
+	}
+	if info.types&typeMask(typ) == 0 {
+		return "", "", fmt.Errorf("'%s' does not apply to datasets of this type", name)
+	}
+	v, msg := info.valid(value)
+	if msg != "" {
+		return "", "", fmt.Errorf("'%s' %s", name, msg)
+	}
+	return name, v, nil
+}
+
+// validateProps validates all props for a dataset of type typ, returning the normalized properties.
+func validateProps(props map[string]string, typ string) (map[string]string, error) {
+	out := make(map[string]string, len(props))
+	for k, v := range props {
+		name, value, err := validateProp(k, v, typ)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = value
+	}
+	return out, nil
+}
+
+var emuPoolProps = map[string]bool{
+	"name": true, "health": true, "size": true, "allocated": true, "free": true, "capacity": true, "readonly": true,
+	"dedupratio": true, "fragmentation": true, "freeing": true, "leaked": true, "guid": true, "altroot": true,
+}
+
+func (e *Emulator) poolProperty(p *emuPool, prop string) (string, string) {
+	allocated := e.used(e.datasets[p.name])
+	switch prop {
+	case "name":
+		return p.name, "-"
+	case "health":
+		return zfs.ZpoolOnline, "-"
+	case "size":
+		return strconv.FormatUint(p.size, 10), "-"
+	case "allocated":
+		return strconv.FormatUint(allocated, 10), "-"
+	case "free":
+		return strconv.FormatUint(p.size-allocated, 10), "-"
+	case "capacity":
+		return strconv.FormatUint(allocated*100/p.size, 10), "-"
+	case "readonly":
+		return "off", "-"
+	case "dedupratio":
+		return "1.00x", "-"
+	case "fragmentation", "freeing", "leaked":
+		return "0", "-"
+	case "guid":
+		return strconv.FormatUint(e.datasets[p.name].guid, 10), "-"
+	}
+	if v, ok := p.props[prop]; ok {
+		return v, "local"
+	}
+	return "-", "default"
+}
@@ -0,0 +1,546 @@
+package zfstest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+)
+
+// emuStream is the header of a record of a send stream produced by the Emulator. A stream holds one record per
+// snapshot, full records preceding the incremental records which build on them. Each header is followed by a
+// payload of Size bytes standing for the data of the snapshot, of which a resumed stream only holds the bytes after
+// Offset.
+type emuStream struct {
+	Snapshot   string            `json:"snapshot"`
+	Type       string            `json:"type"`
+	GUID       uint64            `json:"guid"`
+	FromGUID   uint64            `json:"fromguid,omitempty"`
+	From       string            `json:"from,omitempty"`
+	Creation   int64             `json:"creation"`
+	Referenced uint64            `json:"referenced"`
+	Volsize    string            `json:"volsize,omitempty"`
+	Props      map[string]string `json:"props,omitempty"`
+	Holds      []string          `json:"holds,omitempty"`
+	Size       uint64            `json:"size"`
+	Offset     uint64            `json:"offset,omitempty"`
+}
+
+const streamMagic = "zfstest stream v1\n"
+
+// incrementalSize is the size of the payload of incremental records.
+const incrementalSize = 4096
+
+// emuPartial is the state saved by an interrupted `zfs receive -s`.
+type emuPartial struct {
+	stream   emuStream
+	received uint64
+	// created is true if the receive created the dataset, which aborting the receive destroys.
+	created bool
+}
+
+// token returns the receive_resume_token of p, which encodes the header of the interrupted record.
+func (p *emuPartial) token() string {
+	s := p.stream
+	s.Offset = p.received
+	data, _ := json.Marshal(s)
+	return "1-" + hex.EncodeToString(data)
+}
+
+func parseToken(token string) (*emuStream, error) {
+	var s emuStream
+	data, err := hex.DecodeString(strings.TrimPrefix(token, "1-"))
+	if err != nil || !strings.HasPrefix(token, "1-") || json.Unmarshal(data, &s) != nil {
+		return nil, failf("cannot resume send: resume token is corrupt")
+	}
+	return &s, nil
+}
+
+// zfsSend generates the stream with the lock held, and writes it once the lock is released, so that it can be
+// piped into a receive handled by the same Emulator.
+func (e *Emulator) zfsSend(c *emuCmd) error {
+	spec := "i:I:RwcLepDvnPt:"
+	if e.versionAtLeast(2, 0) {
+		spec += "bhS"
+	}
+	opts, operands, err := getopt(c.args[1:], spec)
+	if err != nil {
+		return err
+	}
+	records, err := e.sendRecords(opts, operands)
+	if err != nil {
+		return err
+	}
+	if opts['n'] != nil {
+		if opts['t'] != nil && opts['v'] != nil {
+			printToken(c, records[0])
+		}
+		if opts['v'] != nil || opts['P'] != nil {
+			printEstimate(c, records, opts['P'] != nil)
+		}
+		return nil
+	}
+	if c.raw == nil {
+		return nil
+	}
+	return writeStream(c.raw, records)
+}
+
+// recordSize returns the number of bytes record takes in a stream.
+func recordSize(record emuStream) uint64 {
+	header, _ := json.Marshal(record)
+	return uint64(len(header)) + 1 + record.Size - record.Offset
+}
+
+// printToken prints the contents of the resume token of record, as `zfs send -nvt` does.
+func printToken(c *emuCmd, record emuStream) {
+	c.printf("resume token contents:\nnvlist version: 0\n")
+	if record.FromGUID != 0 {
+		c.printf("\tfromguid = 0x%x\n", record.FromGUID)
+	}
+	c.printf("\tobject = 0x1\n\toffset = 0x%x\n\tbytes = 0x%x\n", record.Offset, record.Offset)
+	c.printf("\ttoguid = 0x%x\n\ttoname = %s\n", record.GUID, record.Snapshot)
+}
+
+// printEstimate prints the output of `zfs send -nv` for records, parsable with -P.
+func printEstimate(c *emuCmd, records []emuStream, parsable bool) {
+	var total uint64
+	for i, record := range records {
+		size := recordSize(record)
+		if i == 0 {
+			size += uint64(len(streamMagic))
+		}
+		total += size
+		from := record.From
+		switch {
+		case parsable && from == "":
+			c.printf("full\t%s\t%d\n", record.Snapshot, size)
+		case parsable:
+			c.printf("incremental\t%s\t%s\t%d\n", from, record.Snapshot, size)
+		case from == "":
+			c.printf("full send of %s estimated size is %s\n", record.Snapshot, zfs.Size(size))
+		default:
+			c.printf("send from %s to %s estimated size is %s\n", from, record.Snapshot, zfs.Size(size))
+		}
+	}
+	if parsable {
+		c.printf("size\t%d\n", total)
+	} else {
+		c.printf("total estimated size is %s\n", zfs.Size(total))
+	}
+}
+
+func (e *Emulator) sendRecords(opts map[byte][]string, operands []string) ([]emuStream, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if token := opts['t']; token != nil {
+		if len(operands) != 0 {
+			return nil, usagef("too many arguments")
+		}
+		return e.resumeRecords(token[len(token)-1])
+	}
+	if len(operands) != 1 {
+		return nil, usagef("wrong number of arguments")
+	}
+	if opts['S'] != nil {
+		if opts['i'] != nil || opts['I'] != nil || opts['R'] != nil {
+			return nil, usagef("-S cannot be combined with -i, -I or -R")
+		}
+		ds, err := e.lookup(operands[0])
+		if err != nil {
+			return nil, err
+		}
+		return nil, failf("cannot send '%s': dataset does not have saved receive state", ds.name)
+	}
+	snap, err := e.lookup(operands[0])
+	if err != nil {
+		return nil, err
+	}
+	if snap.typ != zfs.DatasetSnapshot {
+		return nil, failf("cannot send '%s': not a snapshot", snap.name)
+	}
+	ds := e.datasets[datasetOf(snap.name)]
+	suffix := snap.name[len(ds.name):]
+
+	from, intermediate := opts['i'], false
+	if opts['I'] != nil {
+		from, intermediate = opts['I'], true
+	}
+	var base *emuDataset
+	if from != nil {
+		name := from[len(from)-1]
+		if strings.HasPrefix(name, "@") || strings.HasPrefix(name, "#") {
+			name = ds.name + name
+		}
+		if base, err = e.lookup(name); err != nil {
+			return nil, err
+		}
+		if datasetOf(base.name) != ds.name && ds.origin != base.name || base.createtxg >= snap.createtxg {
+			return nil, failf("cannot send '%s': not an earlier snapshot from the same fs", snap.name)
+		}
+		if intermediate && base.typ == zfs.DatasetBookmark {
+			return nil, failf("cannot send '%s': -I cannot be used with a bookmark", snap.name)
+		}
+	}
+
+	datasets := []*emuDataset{ds}
+	if opts['R'] != nil {
+		for _, d := range e.descendants(ds.name) {
+			if d.typ == zfs.DatasetFilesystem || d.typ == zfs.DatasetVolume {
+				datasets = append(datasets, d)
+			}
+		}
+	}
+	var records []emuStream
+	for _, d := range datasets {
+		s, ok := e.datasets[d.name+suffix]
+		if !ok {
+			continue
+		}
+		b := base
+		if d != ds && base != nil {
+			b = e.datasets[d.name+base.name[len(datasetOf(base.name)):]]
+		}
+		var chain []*emuDataset
+		for _, earlier := range e.snapshots(d.name) {
+			switch {
+			case earlier.createtxg > s.createtxg:
+			case earlier == s,
+				b == nil && opts['R'] != nil,
+				b != nil && intermediate && earlier.createtxg > b.createtxg:
+				chain = append(chain, earlier)
+			}
+		}
+		var fromGUID uint64
+		var fromName string
+		if b != nil {
+			fromGUID, fromName = b.guid, b.name
+		}
+		for _, s := range chain {
+			record := emuStream{
+				Snapshot:   s.name,
+				Type:       d.typ,
+				GUID:       s.guid,
+				FromGUID:   fromGUID,
+				From:       fromName,
+				Creation:   s.creation,
+				Referenced: s.referenced,
+				Volsize:    d.props["volsize"],
+				Size:       s.referenced,
+			}
+			if fromGUID != 0 {
+				record.Size = incrementalSize
+			}
+			if opts['p'] != nil || opts['R'] != nil {
+				record.Props = copyProps(d.props)
+				delete(record.Props, "volsize")
+			}
+			if opts['h'] != nil {
+				for tag := range s.holds {
+					record.Holds = append(record.Holds, tag)
+				}
+				sort.Strings(record.Holds)
+			}
+			records = append(records, record)
+			fromGUID, fromName = s.guid, s.name
+		}
+	}
+	return records, nil
+}
+
+// resumeRecords returns the rest of the record interrupted at the point saved in token.
+func (e *Emulator) resumeRecords(token string) ([]emuStream, error) {
+	s, err := parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if snap, ok := e.datasets[s.Snapshot]; !ok || snap.guid != s.GUID {
+		return nil, failf("cannot resume send: '%s' used in the initial send no longer exists", s.Snapshot)
+	}
+	return []emuStream{*s}, nil
+}
+
+func writeStream(w io.Writer, records []emuStream) error {
+	bw := bufio.NewWriter(w)
+	if _, err := io.WriteString(bw, streamMagic); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(bw)
+	zeros := make([]byte, 32<<10)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+		for n := record.Size - record.Offset; n > 0; {
+			chunk := zeros
+			if n < uint64(len(chunk)) {
+				chunk = chunk[:n]
+			}
+			if _, err := bw.Write(chunk); err != nil {
+				return err
+			}
+			n -= uint64(len(chunk))
+		}
+	}
+	return bw.Flush()
+}
+
+// zfsReceive reads the whole stream before taking the lock, so that it can be piped from a send handled by the same
+// Emulator. A stream which ends early, or fails to be read, is handled as an interrupted transfer.
+func (e *Emulator) zfsReceive(c *emuCmd) error {
+	opts, operands, err := getopt(c.args[1:], "AFnuvsdeo:x:")
+	if err != nil {
+		return err
+	}
+	if len(operands) != 1 {
+		return usagef("wrong number of arguments")
+	}
+	if opts['A'] != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return e.abortReceive(operands[0])
+	}
+	if opts['d'] != nil && opts['e'] != nil {
+		return usagef("-d and -e cannot be used together")
+	}
+	override, err := parseProps(opts['o'])
+	if err != nil {
+		return err
+	}
+	for p := range override {
+		if _, ok := canonicalProp(p); !ok {
+			return failf("cannot receive: invalid property '%s'", p)
+		}
+	}
+
+	data, _ := ioutil.ReadAll(c.stdin)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	r := bufio.NewReader(bytes.NewReader(data))
+	if magic, err := r.ReadString('\n'); err != nil || magic != streamMagic {
+		return failf("cannot receive: invalid stream (bad magic number)")
+	}
+	target := operands[0]
+	dryRun := opts['n'] != nil
+	var root string
+	for i := 0; ; i++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 && i > 0 {
+			return nil
+		}
+		stream := &emuStream{}
+		if err != nil || json.Unmarshal(line, stream) != nil {
+			return failf("cannot receive: invalid stream")
+		}
+
+		if i == 0 {
+			root = datasetOf(stream.Snapshot)
+			if opts['d'] != nil || opts['e'] != nil {
+				if target != datasetOf(target) {
+					return usagef("-d and -e require a filesystem as the destination")
+				}
+				if _, err := e.lookup(target); err != nil {
+					return failf("cannot receive: destination '%s' does not exist", target)
+				}
+				// The sent name below root is appended to the destination, after the path kept from root itself.
+				if opts['e'] != nil {
+					target += "/" + root[strings.LastIndexByte(root, '/')+1:]
+				} else if i := strings.IndexByte(root, '/'); i >= 0 {
+					target += root[i:]
+				}
+			}
+		} else if target != datasetOf(target) {
+			return failf("cannot receive: a snapshot name can only be given for a stream of a single snapshot")
+		}
+
+		name := datasetOf(target) + datasetOf(stream.Snapshot)[len(root):]
+		snapName := name + stream.Snapshot[len(datasetOf(stream.Snapshot)):]
+		if target != datasetOf(target) {
+			snapName = target
+		}
+		if opts['v'] != nil {
+			verb, kind := "receiving", "full"
+			if dryRun {
+				verb = "would receive"
+			}
+			if stream.FromGUID != 0 {
+				kind = "incremental"
+			}
+			c.printf("%s %s stream of %s into %s\n", verb, kind, stream.Snapshot, snapName)
+		}
+		received, _ := io.CopyN(ioutil.Discard, r, int64(stream.Size-stream.Offset))
+		// A dry run does not create the snapshots later records build on.
+		if dryRun && i > 0 {
+			continue
+		}
+
+		props := copyProps(stream.Props)
+		for _, p := range splitList(opts['x']) {
+			delete(props, p)
+		}
+		for p, v := range override {
+			if name == datasetOf(target) {
+				props[p] = v
+			} else {
+				delete(props, p)
+			}
+		}
+		if opts['d'] != nil && !dryRun {
+			e.addParents(name)
+		}
+		if err := e.receive(opts, stream, props, name, snapName, uint64(received)); err != nil {
+			return err
+		}
+	}
+}
+
+// receive applies a record of a send stream with the given properties to the dataset name, creating the snapshot
+// snapName. Only received bytes of the payload of the record were read: if it is incomplete, the partially received
+// state is saved on the dataset with -s.
+func (e *Emulator) receive(opts map[byte][]string, stream *emuStream, props map[string]string, name, snapName string, received uint64) error {
+	if _, ok := e.pools[poolOf(name)]; !ok {
+		return failf("cannot open '%s': dataset does not exist", poolOf(name))
+	}
+	ds, exists := e.datasets[name]
+	kind := "new filesystem"
+	if stream.FromGUID != 0 {
+		kind = "incremental"
+	}
+
+	if exists && ds.partial != nil {
+		p := ds.partial
+		if p.stream.GUID != stream.GUID {
+			return failf("cannot receive %s stream: destination %s contains partially-complete state from \"zfs receive -s\".", kind, name)
+		}
+		if stream.Offset != p.received {
+			return failf("cannot receive resume stream: stream does not continue the partially-complete state of %s", name)
+		}
+		if opts['n'] != nil {
+			return nil
+		}
+		p.received += received
+		if p.received < p.stream.Size {
+			return e.interrupted(opts, kind, p)
+		}
+		ds.partial = nil
+		e.received(ds, stream, props, snapName)
+		return nil
+	}
+	if stream.Offset != 0 {
+		return failf("cannot receive resume stream: destination %s has no partially-complete state", name)
+	}
+
+	var newer []*emuDataset
+	if stream.FromGUID == 0 {
+		if exists {
+			if opts['F'] == nil {
+				return failf("cannot receive new filesystem stream: destination '%s' exists\nmust specify -F to overwrite it", name)
+			}
+			if snaps := e.snapshots(name); len(snaps) > 0 {
+				return failf("cannot receive new filesystem stream: destination has snapshots (eg. %s)\nmust destroy them to overwrite it", snaps[0].name)
+			}
+		} else if _, ok := e.datasets[parentOf(name)]; !ok && opts['d'] == nil {
+			return failf("cannot receive new filesystem stream: parent of '%s' does not exist", name)
+		}
+	} else {
+		if !exists {
+			return failf("cannot receive incremental stream: destination '%s' does not exist", name)
+		}
+		var base *emuDataset
+		for _, s := range e.snapshots(name) {
+			if base != nil {
+				newer = append(newer, s)
+			}
+			if s.guid == stream.FromGUID {
+				base = s
+			}
+		}
+		if base == nil {
+			return failf("cannot receive incremental stream: most recent snapshot of %s does not\nmatch incremental source", name)
+		}
+		if len(newer) > 0 {
+			if opts['F'] == nil {
+				return failf("cannot receive incremental stream: destination %s has been modified\nsince most recent snapshot", name)
+			}
+			if clones := e.dependentClones(newer); len(clones) > 0 {
+				return failf("cannot receive incremental stream: destination %s has dependent clones", name)
+			}
+		}
+		if _, ok := e.datasets[snapName]; ok {
+			return failf("cannot restore to %s: destination already exists", snapName)
+		}
+	}
+	if opts['n'] != nil {
+		return nil
+	}
+
+	e.destroy(nil, newer, false, false)
+	if !exists {
+		ds = e.add(name, stream.Type, map[string]string{})
+		if stream.Volsize != "" {
+			ds.props["volsize"] = stream.Volsize
+		}
+	}
+	if received < stream.Size {
+		p := &emuPartial{stream: *stream, received: received, created: !exists}
+		if opts['s'] != nil {
+			ds.partial = p
+		} else if !exists {
+			delete(e.datasets, name)
+		}
+		return e.interrupted(opts, kind, p)
+	}
+	e.received(ds, stream, props, snapName)
+	return nil
+}
+
+// interrupted returns the error of a receive interrupted by the end of the stream.
+func (e *Emulator) interrupted(opts map[byte][]string, kind string, p *emuPartial) error {
+	msg := "cannot receive " + kind + " stream: checksum mismatch or incomplete stream"
+	if opts['s'] != nil {
+		msg += ".\nPartially received snapshot is saved.\nA resuming stream can be generated on the sending system by running:\n    zfs send -t " + p.token()
+	}
+	return failf("%s", msg)
+}
+
+// received creates the snapshot snapName of ds from a completely received record.
+func (e *Emulator) received(ds *emuDataset, stream *emuStream, props map[string]string, snapName string) {
+	for k, v := range props {
+		ds.props[k] = v
+	}
+	ds.referenced = stream.Referenced
+	snap := e.snapshot(snapName, nil)
+	snap.guid = stream.GUID
+	snap.creation = stream.Creation
+	for _, tag := range stream.Holds {
+		if snap.holds == nil {
+			snap.holds = map[string]time.Time{}
+		}
+		snap.holds[tag] = e.now()
+	}
+}
+
+// abortReceive discards the partially received state of name, destroying the dataset if the receive created it.
+func (e *Emulator) abortReceive(name string) error {
+	ds, err := e.lookup(name)
+	if err != nil {
+		return err
+	}
+	if ds.partial == nil {
+		return failf("'%s' does not have any resumable receive state to abort", name)
+	}
+	if ds.partial.created {
+		delete(e.datasets, name)
+	}
+	ds.partial = nil
+	return nil
+}
@@ -0,0 +1,395 @@
+package zfstest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+	"github.com/mistifyio/go-zfs/v4/zfstest"
+)
+
+func ok(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func stderrContains(t *testing.T, err error, want string) {
+	t.Helper()
+	var e *zfs.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("wanted *zfs.Error, got %T (%[1]v)", err)
+	}
+	if !strings.Contains(e.Stderr, want) {
+		t.Fatalf("wanted stderr containing %q, got %q", want, e.Stderr)
+	}
+}
+
+func names(datasets []*zfs.Dataset) []string {
+	out := make([]string, len(datasets))
+	for i, ds := range datasets {
+		out[i] = ds.Name
+	}
+	return out
+}
+
+func setupEmulator(t *testing.T) *zfstest.Emulator {
+	t.Helper()
+
+	emu := zfstest.NewEmulator()
+	useExecutor(t, emu)
+	_, err := zfs.CreateZpool("tank", nil, "/dev/null")
+	ok(t, err)
+	return emu
+}
+
+func TestEmulatorHierarchy(t *testing.T) {
+	setupEmulator(t)
+
+	_, err := zfs.CreateFilesystem("tank/a/b", nil)
+	stderrContains(t, err, "parent does not exist")
+
+	a, err := zfs.CreateFilesystem("tank/a", map[string]string{"compression": "lz4", "mountpoint": "/srv"})
+	ok(t, err)
+	b, err := zfs.CreateFilesystem("tank/a/b", nil)
+	ok(t, err)
+	if b.Compression != "lz4" || b.Mountpoint != "/srv/b" {
+		t.Fatalf("properties were not inherited: %+v", b)
+	}
+	_, err = b.Snapshot("s1", false)
+	ok(t, err)
+	_, err = a.Snapshot("s1", true)
+	stderrContains(t, err, "dataset already exists")
+	_, err = a.Snapshot("s2", true)
+	ok(t, err)
+
+	all, err := zfs.Datasets("tank")
+	ok(t, err)
+	if got := strings.Join(names(all), " "); got != "tank tank/a tank/a@s2 tank/a/b tank/a/b@s1 tank/a/b@s2" {
+		t.Fatalf("unexpected listing: %s", got)
+	}
+
+	children, err := a.Children(1)
+	ok(t, err)
+	if got := strings.Join(names(children), " "); got != "tank/a@s2 tank/a/b" {
+		t.Fatalf("unexpected children: %s", got)
+	}
+
+	compression, err := b.GetProperty("compression")
+	ok(t, err)
+	if compression != "lz4" {
+		t.Fatalf("unexpected compression: %s", compression)
+	}
+
+	stderrContains(t, a.Destroy(zfs.DestroyDefault), "filesystem has children")
+	ok(t, a.Destroy(zfs.DestroyRecursive))
+}
+
+func TestEmulatorClones(t *testing.T) {
+	setupEmulator(t)
+
+	fs, err := zfs.CreateFilesystem("tank/fs", nil)
+	ok(t, err)
+	snap, err := fs.Snapshot("base", false)
+	ok(t, err)
+	clone, err := snap.Clone("tank/clone", nil)
+	ok(t, err)
+	if clone.Origin != "tank/fs@base" {
+		t.Fatalf("unexpected origin: %q", clone.Origin)
+	}
+
+	err = snap.Destroy(zfs.DestroyDefault)
+	stderrContains(t, err, "snapshot has dependent clones")
+	if !errors.Is(err, zfs.ErrHasClones) {
+		t.Fatalf("wanted ErrHasClones, got %v", err)
+	}
+	stderrContains(t, fs.Destroy(zfs.DestroyRecursive), "filesystem has dependent clones")
+
+	renamed, err := fs.Rename("tank/renamed", false, false)
+	ok(t, err)
+	clone, err = zfs.GetDataset("tank/clone")
+	ok(t, err)
+	if clone.Origin != "tank/renamed@base" {
+		t.Fatalf("origin was not renamed: %q", clone.Origin)
+	}
+
+	ok(t, renamed.Destroy(zfs.DestroyRecursiveClones))
+	_, err = zfs.GetDataset("tank/clone")
+	if !errors.Is(err, zfs.ErrNotFound) {
+		t.Fatalf("wanted ErrNotFound, got %v", err)
+	}
+}
+
+func TestEmulatorRollback(t *testing.T) {
+	setupEmulator(t)
+
+	fs, err := zfs.CreateFilesystem("tank/fs", nil)
+	ok(t, err)
+	s1, err := fs.Snapshot("s1", false)
+	ok(t, err)
+	s2, err := fs.Snapshot("s2", false)
+	ok(t, err)
+	_, err = s2.Clone("tank/clone", nil)
+	ok(t, err)
+
+	stderrContains(t, s1.Rollback(false), "more recent snapshots or bookmarks exist")
+	stderrContains(t, s1.Rollback(true), "clones of previous snapshots exist")
+
+	c, err := zfs.GetDataset("tank/clone")
+	ok(t, err)
+	ok(t, c.Destroy(zfs.DestroyDefault))
+	ok(t, s1.Rollback(true))
+
+	snaps, err := fs.Snapshots()
+	ok(t, err)
+	if got := strings.Join(names(snaps), " "); got != "tank/fs@s1" {
+		t.Fatalf("unexpected snapshots: %s", got)
+	}
+}
+
+func TestEmulatorSendReceive(t *testing.T) {
+	setupEmulator(t)
+
+	fs, err := zfs.CreateFilesystem("tank/src", nil)
+	ok(t, err)
+	s1, err := fs.Snapshot("s1", false)
+	ok(t, err)
+	s2, err := fs.Snapshot("s2", false)
+	ok(t, err)
+
+	var full, incr bytes.Buffer
+	ok(t, s1.SendSnapshot(&full))
+	ok(t, s2.IncrementalSend(s1, &incr))
+
+	_, err = zfs.ReceiveSnapshot(bytes.NewReader(incr.Bytes()), "tank/dst@s2")
+	stderrContains(t, err, "does not exist")
+
+	r1, err := zfs.ReceiveSnapshot(&full, "tank/dst@s1")
+	ok(t, err)
+	g1, err := r1.GetProperty("guid")
+	ok(t, err)
+	src1, err := s1.GetProperty("guid")
+	ok(t, err)
+	if g1 != src1 {
+		t.Fatalf("received snapshot has guid %s, wanted %s", g1, src1)
+	}
+
+	_, err = zfs.ReceiveSnapshot(&incr, "tank/dst@s2")
+	ok(t, err)
+}
+
+func TestEmulatorSetProperty(t *testing.T) {
+	setupEmulator(t)
+
+	fs, err := zfs.CreateFilesystem("tank/fs", nil)
+	ok(t, err)
+	stderrContains(t, fs.SetProperty("used", "1"), "'used' is readonly")
+	stderrContains(t, fs.SetProperty("compression", "fast"), "'compression' must be one of")
+	stderrContains(t, fs.SetProperty("volsize", "1G"), "does not apply to datasets of this type")
+	ok(t, fs.SetProperty("quota", "1G"))
+	ok(t, fs.SetProperty("com.example:owner", "alice"))
+
+	fs, err = zfs.GetDataset("tank/fs")
+	ok(t, err)
+	if fs.Quota != 1<<30 {
+		t.Fatalf("unexpected quota: %d", fs.Quota)
+	}
+	if fs.Avail > fs.Quota {
+		t.Fatalf("available space %d exceeds quota", fs.Avail)
+	}
+	owner, err := fs.GetProperty("com.example:owner")
+	ok(t, err)
+	if owner != "alice" {
+		t.Fatalf("unexpected user property: %q", owner)
+	}
+
+	stderrContains(t, fs.InheritProperty("quota", false, false), "'quota' property cannot be inherited")
+	stderrContains(t, fs.InheritProperty("used", false, false), "'used' property is read-only")
+	ok(t, fs.InheritProperty("com.example:owner", false, false))
+	owner, err = fs.GetProperty("com.example:owner")
+	ok(t, err)
+	if owner != "-" {
+		t.Fatalf("unexpected user property after inherit: %q", owner)
+	}
+}
+
+func TestEmulatorJSON(t *testing.T) {
+	state := func(version string) ([]*zfs.Dataset, []*zfs.Zpool, string) {
+		emu := zfstest.NewEmulator()
+		emu.Version = version
+		useExecutor(t, emu)
+		_, err := zfs.CreateZpool("tank", nil, "/dev/null")
+		ok(t, err)
+		fs, err := zfs.CreateFilesystem("tank/fs", map[string]string{"compression": "lz4"})
+		ok(t, err)
+		_, err = fs.Snapshot("snap", false)
+		ok(t, err)
+		_, err = zfs.CreateFilesystem("tank/fs/child", nil)
+		ok(t, err)
+
+		datasets, err := zfs.Datasets("tank")
+		ok(t, err)
+		pools, err := zfs.ListZpools()
+		ok(t, err)
+		compression, err := datasets[2].GetProperty("compression")
+		ok(t, err)
+		return datasets, pools, compression
+	}
+
+	tabDatasets, tabPools, tabCompression := state("2.1.5")
+	jsonDatasets, jsonPools, jsonCompression := state("2.3.0")
+	if got, want := names(jsonDatasets), []string{"tank", "tank/fs", "tank/fs@snap", "tank/fs/child"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected datasets: %v", got)
+	}
+	if !reflect.DeepEqual(jsonDatasets, tabDatasets) {
+		t.Fatalf("JSON and tab separated output differ:\n%+v\n%+v", jsonDatasets, tabDatasets)
+	}
+	if !reflect.DeepEqual(jsonPools, tabPools) {
+		t.Fatalf("JSON and tab separated output differ:\n%+v\n%+v", jsonPools, tabPools)
+	}
+	if jsonCompression != "lz4" || tabCompression != "lz4" {
+		t.Fatalf("unexpected compression: %q, %q", jsonCompression, tabCompression)
+	}
+
+	cmd := &zfs.Cmd{Path: "zfs", Args: []string{"list", "-j"}}
+	emu := &zfstest.Emulator{Version: "2.2.6"}
+	if err := emu.Exec(context.Background(), cmd); err == nil {
+		t.Fatal("wanted -j to be rejected before 2.3")
+	}
+}
+
+func TestEmulatorBookmark(t *testing.T) {
+	setupEmulator(t)
+
+	fs, err := zfs.CreateFilesystem("tank/fs", nil)
+	ok(t, err)
+	s, err := fs.Snapshot("snap", false)
+	ok(t, err)
+	b, err := s.Bookmark("mark")
+	ok(t, err)
+	_, err = s.Bookmark("mark")
+	stderrContains(t, err, "bookmark exists")
+
+	copied, err := b.Bookmark("copy")
+	ok(t, err)
+	if copied.Name != "tank/fs#copy" {
+		t.Fatalf("unexpected bookmark: %q", copied.Name)
+	}
+
+	bookmarks, err := zfs.Bookmarks("tank")
+	ok(t, err)
+	if got, want := names(bookmarks), []string{"tank/fs#mark", "tank/fs#copy"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected bookmarks: %v", got)
+	}
+
+	emu := zfstest.NewEmulator()
+	emu.Version = "0.8.6"
+	useExecutor(t, emu)
+	if _, err := b.Bookmark("old"); !errors.Is(err, zfs.ErrUnsupported) {
+		t.Fatalf("wanted ErrUnsupported, got %v", err)
+	}
+}
+
+// interrupter cuts the stream of the first receives after limit bytes.
+type interrupter struct {
+	zfs.Executor
+	limit int64
+	count int
+}
+
+func (i *interrupter) Exec(ctx context.Context, cmd *zfs.Cmd) error {
+	if len(cmd.Args) > 0 && cmd.Args[0] == "receive" && cmd.Stdin != nil && i.count > 0 {
+		i.count--
+		cmd.Stdin = io.LimitReader(cmd.Stdin, i.limit)
+	}
+	return i.Executor.Exec(ctx, cmd)
+}
+
+func TestEmulatorResumableReceive(t *testing.T) {
+	emu := setupEmulator(t)
+	fs, err := zfs.CreateFilesystem("tank/src", nil)
+	ok(t, err)
+	snap, err := fs.Snapshot("s1", false)
+	ok(t, err)
+
+	var stream bytes.Buffer
+	ok(t, snap.SendSnapshot(&stream))
+	cut := bytes.NewReader(stream.Bytes()[:stream.Len()/2])
+	_, err = zfs.Receive(cut, "tank/dst", zfs.ReceiveOptions{Resumable: true})
+	stderrContains(t, err, "Partially received snapshot is saved")
+
+	dst, err := zfs.GetDataset("tank/dst")
+	ok(t, err)
+	token, err := dst.ResumeToken()
+	ok(t, err)
+	if token == "" {
+		t.Fatal("wanted a resume token")
+	}
+	_, err = zfs.Receive(bytes.NewReader(stream.Bytes()), "tank/dst", zfs.ReceiveOptions{Resumable: true})
+	stderrContains(t, err, "partially-complete state")
+
+	var rest bytes.Buffer
+	ok(t, zfs.ResumeSend(token, &rest))
+	if rest.Len() >= stream.Len() {
+		t.Fatalf("resumed stream of %d bytes is not shorter than the full stream of %d bytes", rest.Len(), stream.Len())
+	}
+	res, err := zfs.Receive(&rest, "tank/dst", zfs.ReceiveOptions{Resumable: true})
+	ok(t, err)
+	if res.Dataset.Name != "tank/dst" {
+		t.Fatalf("unexpected dataset: %s", res.Dataset.Name)
+	}
+	token, err = dst.ResumeToken()
+	ok(t, err)
+	if token != "" {
+		t.Fatalf("unexpected resume token after completion: %q", token)
+	}
+
+	_, err = zfs.Receive(bytes.NewReader(stream.Bytes()[:stream.Len()/2]), "tank/aborted", zfs.ReceiveOptions{Resumable: true})
+	stderrContains(t, err, "incomplete stream")
+	aborted := &zfs.Dataset{Name: "tank/aborted"}
+	ok(t, aborted.AbortReceive())
+	_, err = zfs.GetDataset("tank/aborted")
+	if !errors.Is(err, zfs.ErrNotFound) {
+		t.Fatalf("wanted ErrNotFound after abort, got %v", err)
+	}
+	stderrContains(t, aborted.AbortReceive(), "does not exist")
+
+	useExecutor(t, &interrupter{Executor: emu, limit: 1000, count: 2})
+	_, err = snap.SendResumable(nil, "tank/retried", zfs.ResumableOptions{Retries: 1})
+	stderrContains(t, err, "incomplete stream")
+	res, err = snap.SendResumable(nil, "tank/retried", zfs.ResumableOptions{Retries: 1})
+	ok(t, err)
+	if res.Dataset.Name != "tank/retried" {
+		t.Fatalf("unexpected dataset: %s", res.Dataset.Name)
+	}
+	_, err = zfs.GetDataset("tank/retried@s1")
+	ok(t, err)
+}
+
+func TestEmulatorPrefix(t *testing.T) {
+	emu := zfstest.NewEmulator()
+	c := &zfs.Client{Runner: &zfs.Runner{Executor: emu, Prefix: []string{"sudo", "-n", "-u", "root"}}}
+	_, err := c.CreateZpool("tank", nil, "/dev/null")
+	ok(t, err)
+	_, err = c.CreateFilesystem("tank/fs", nil)
+	ok(t, err)
+	_, err = c.GetDataset("tank/fs")
+	ok(t, err)
+
+	emu.Refuse = "sudo: a password is required"
+	_, err = c.GetDataset("tank/fs")
+	if !errors.Is(err, zfs.ErrPrivilegeEscalation) {
+		t.Fatalf("wanted ErrPrivilegeEscalation, got %v", err)
+	}
+	stderrContains(t, err, "a password is required")
+
+	direct := &zfs.Client{Runner: &zfs.Runner{Executor: emu}}
+	_, err = direct.GetDataset("tank/fs")
+	ok(t, err)
+}
@@ -0,0 +1,207 @@
+// Package zfstest provides executors which allow code using go-zfs to be tested without a ZFS installation.
+//
+// Install one of the executors on a zfs.Runner:
+//
+//	fake := &zfstest.Fake{}
+//	fake.On("zfs", "list", "...").Return("tank\t-\t...\n")
+//	zfs.SetRunner(&zfs.Runner{Executor: fake})
+//
+// Clients run `zfs version` before their first command to detect the supported features. Fake answers it on its
+// own unless a rule matches it, so that scripts only need rules for the commands under test.
+package zfstest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+)
+
+// FakeVersion is the OpenZFS version reported by a Fake when Fake.Version is empty. It predates JSON output, so that
+// rules written for the tab separated output of the zfs and zpool commands apply.
+const FakeVersion = "2.2.0"
+
+// ExitError is returned by the executors in this package when a command exits with a non-zero status.
+type ExitError struct {
+	Code int
+}
+
+// Error returns the string representation of an ExitError, matching the one used by *exec.ExitError.
+func (e *ExitError) Error() string {
+	return "exit status " + strconv.Itoa(e.Code)
+}
+
+// ExitCode returns the exit code of the command.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Call is a command which was run by a Fake.
+type Call struct {
+	Argv  []string
+	Stdin []byte
+}
+
+// Fake is a zfs.Executor which answers commands from a script of canned responses.
+//
+// Rules are matched in the order they were added, commands which do not match any rule fail with exit code 127,
+// except `zfs version` which reports Version. The zero value is ready to use.
+type Fake struct {
+	// Version is the OpenZFS version reported by `zfs version` when no rule matches it, such as "2.1.5". Those
+	// answers are not recorded by Calls. If empty, FakeVersion is used.
+	Version string
+
+	mu    sync.Mutex
+	rules []*Rule
+	calls []Call
+}
+
+// Rule is a canned response of a Fake.
+type Rule struct {
+	fake     *Fake
+	pattern  []string
+	stdout   string
+	stderr   string
+	exitCode int
+	times    int
+	used     int
+}
+
+// On adds a rule for commands whose argument vector, including the command name, matches pattern.
+// Each element of pattern must be equal to the argument at the same position, except "*" which matches any single
+// argument, and a final "..." which matches any remaining arguments.
+// The rule succeeds without output until configured otherwise.
+func (f *Fake) On(pattern ...string) *Rule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r := &Rule{fake: f, pattern: pattern}
+	f.rules = append(f.rules, r)
+	return r
+}
+
+// Return sets the standard output written by commands matching the rule.
+func (r *Rule) Return(stdout string) *Rule {
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	r.stdout = stdout
+	return r
+}
+
+// Fail makes commands matching the rule exit with code and write stderr to their standard error.
+func (r *Rule) Fail(code int, stderr string) *Rule {
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	r.exitCode = code
+	r.stderr = stderr
+	return r
+}
+
+// Times limits the rule to match only n commands, after which it is skipped. A value of 0 means no limit.
+func (r *Rule) Times(n int) *Rule {
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	r.times = n
+	return r
+}
+
+func (r *Rule) match(argv []string) bool {
+	if r.times != 0 && r.used >= r.times {
+		return false
+	}
+	for i, p := range r.pattern {
+		if p == "..." && i == len(r.pattern)-1 {
+			return true
+		}
+		if i >= len(argv) || (p != "*" && p != argv[i]) {
+			return false
+		}
+	}
+	return len(argv) == len(r.pattern)
+}
+
+// Calls returns the commands run so far, in order.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Call(nil), f.calls...)
+}
+
+// Exec implements zfs.Executor.
+func (f *Fake) Exec(ctx context.Context, cmd *zfs.Cmd) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	argv := cmd.Argv()
+	call := Call{Argv: argv}
+	if cmd.Stdin != nil {
+		var err error
+		if call.Stdin, err = ioutil.ReadAll(cmd.Stdin); err != nil {
+			return err
+		}
+	}
+
+	f.mu.Lock()
+	var rule *Rule
+	for _, r := range f.rules {
+		if r.match(argv) {
+			rule = r
+			r.used++
+			break
+		}
+	}
+	var stdout, stderr string
+	var exitCode int
+	switch {
+	case rule != nil:
+		f.calls = append(f.calls, call)
+		stdout, stderr, exitCode = rule.stdout, rule.stderr, rule.exitCode
+	case isVersion(argv):
+		version := f.Version
+		if version == "" {
+			version = FakeVersion
+		}
+		stdout = fmt.Sprintf("zfs-%s-1\nzfs-kmod-%[1]s-1\n", version)
+	default:
+		f.calls = append(f.calls, call)
+		stderr = fmt.Sprintf("zfstest: unexpected command: %s\n", strings.Join(argv, " "))
+		exitCode = 127
+	}
+	f.mu.Unlock()
+
+	if err := write(cmd.Stdout, stdout); err != nil {
+		return err
+	}
+	if err := write(cmd.Stderr, stderr); err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return &ExitError{Code: exitCode}
+	}
+	return nil
+}
+
+// isVersion reports whether argv is `zfs version`, possibly run through a privilege escalation prefix.
+func isVersion(argv []string) bool {
+	n := len(argv)
+	return n >= 2 && argv[n-1] == "version" && path.Base(argv[n-2]) == "zfs"
+}
+
+func write(w io.Writer, s string) error {
+	if w == nil || s == "" {
+		return nil
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
@@ -0,0 +1,158 @@
+package zfstest_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	zfs "github.com/mistifyio/go-zfs/v4"
+	"github.com/mistifyio/go-zfs/v4/zfstest"
+)
+
+func useExecutor(t *testing.T, e zfs.Executor) {
+	t.Helper()
+
+	old := zfs.Default()
+	zfs.SetRunner(&zfs.Runner{Executor: e})
+	t.Cleanup(func() { zfs.SetRunner(old) })
+}
+
+func TestFakeGetDataset(t *testing.T) {
+	fake := &zfstest.Fake{}
+	fake.On("zfs", "list", "-Hp", "-o", "...").
+		Return("test\t-\t1024\t2048\t/test\ton\tfilesystem\t-\t0\t512\t512\t1024\t512\n")
+	useExecutor(t, fake)
+
+	ds, err := zfs.GetDataset("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Name != "test" || ds.Used != 1024 || ds.Avail != 2048 || ds.Mountpoint != "/test" || ds.Type != zfs.DatasetFilesystem {
+		t.Fatalf("unexpected dataset: %+v", ds)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("wanted 1 call, got %d", len(calls))
+	}
+	if calls[0].Argv[0] != "zfs" || calls[0].Argv[len(calls[0].Argv)-1] != "test" {
+		t.Fatalf("unexpected argv: %v", calls[0].Argv)
+	}
+}
+
+func TestFakeGetDatasetJSON(t *testing.T) {
+	fake := &zfstest.Fake{Version: "2.3.0"}
+	fake.On("zfs", "list", "-j", "-p", "-o", "...").Return(`{
+  "output_version": {"command": "zfs list", "vers_major": 0, "vers_minor": 1},
+  "datasets": {
+    "test": {
+      "name": "test",
+      "type": "FILESYSTEM",
+      "pool": "test",
+      "createtxg": "1",
+      "properties": {
+        "origin": {"value": "-", "source": {"type": "NONE", "data": "-"}},
+        "used": {"value": "1024", "source": {"type": "NONE", "data": "-"}},
+        "available": {"value": "2048", "source": {"type": "NONE", "data": "-"}},
+        "mountpoint": {"value": "/test", "source": {"type": "DEFAULT", "data": "-"}},
+        "compression": {"value": "lz4", "source": {"type": "LOCAL", "data": "-"}}
+      }
+    }
+  }
+}
+`)
+	useExecutor(t, fake)
+
+	ds, err := zfs.GetDataset("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Name != "test" || ds.Used != 1024 || ds.Avail != 2048 || ds.Mountpoint != "/test" || ds.Compression != "lz4" || ds.Type != zfs.DatasetFilesystem {
+		t.Fatalf("unexpected dataset: %+v", ds)
+	}
+}
+
+func TestFakeVersion(t *testing.T) {
+	fake := &zfstest.Fake{}
+	useExecutor(t, fake)
+	v, err := zfs.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != zfstest.FakeVersion || len(fake.Calls()) != 0 {
+		t.Fatalf("unexpected version %s with calls %v", v, fake.Calls())
+	}
+
+	fake = &zfstest.Fake{}
+	fake.On("zfs", "version").Fail(255, "ssh: connect to host storage1 port 22: Connection refused\n")
+	useExecutor(t, fake)
+	if _, err := zfs.Version(); err == nil {
+		t.Fatal("wanted the error scripted for zfs version")
+	}
+	if calls := fake.Calls(); len(calls) != 1 {
+		t.Fatalf("wanted the scripted zfs version to be recorded, got %v", calls)
+	}
+}
+
+func TestFakeFail(t *testing.T) {
+	fake := &zfstest.Fake{}
+	fake.On("zfs", "destroy", "*").Fail(1, "cannot open 'test/nope': dataset does not exist\n")
+	useExecutor(t, fake)
+
+	err := (&zfs.Dataset{Name: "test/nope"}).Destroy(zfs.DestroyDefault)
+	var e *zfs.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("wanted *zfs.Error, got %T (%[1]v)", err)
+	}
+	if !strings.Contains(e.Stderr, "does not exist") {
+		t.Fatalf("unexpected stderr: %q", e.Stderr)
+	}
+	if e.Debug != "zfs destroy test/nope" {
+		t.Fatalf("unexpected debug: %q", e.Debug)
+	}
+	var exit *zfstest.ExitError
+	if !errors.As(e.Err, &exit) || exit.ExitCode() != 1 {
+		t.Fatalf("wanted exit code 1, got %v", e.Err)
+	}
+}
+
+func TestFakeRules(t *testing.T) {
+	fake := &zfstest.Fake{}
+	fake.On("zfs", "get", "-Hp", "compression", "*").Return("a\tcompression\tlz4\tlocal\n").Times(1)
+	fake.On("zfs", "get", "-Hp", "compression", "*").Return("a\tcompression\toff\tdefault\n")
+	useExecutor(t, fake)
+
+	ds := &zfs.Dataset{Name: "a"}
+	for _, want := range []string{"lz4", "off", "off"} {
+		got, err := ds.GetProperty("compression")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("wanted %q, got %q", want, got)
+		}
+	}
+
+	_, err := ds.GetProperty("mountpoint")
+	var e *zfs.Error
+	if !errors.As(err, &e) || !strings.Contains(e.Stderr, "unexpected command") {
+		t.Fatalf("wanted unexpected command error, got %v", err)
+	}
+}
+
+func TestFakeStdin(t *testing.T) {
+	fake := &zfstest.Fake{}
+	fake.On("zfs", "receive", "test/recv")
+	fake.On("zfs", "list", "...").
+		Return("test/recv\t-\t0\t0\t/test/recv\toff\tfilesystem\t-\t0\t0\t0\t0\t0\n")
+	useExecutor(t, fake)
+
+	if _, err := zfs.ReceiveSnapshot(strings.NewReader("stream"), "test/recv"); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	if !reflect.DeepEqual(calls[0].Stdin, []byte("stream")) {
+		t.Fatalf("unexpected stdin: %q", calls[0].Stdin)
+	}
+}
@@ -1,5 +1,7 @@
 package zfs
 
+import "context"
+
 // ZFS zpool states, which can indicate if a pool is online, offline, degraded, etc.
 //
 // More information regarding zpool states can be found in the ZFS manual:
@@ -26,30 +28,33 @@ type Zpool struct {
 	Freeing       uint64
 	Leaked        uint64
 	DedupRatio    float64
+
+	client *Client
 }
 
-// zpool is a helper function to wrap typical calls to zpool and ignores stdout.
-func zpool(arg ...string) error {
-	_, err := zpoolOutput(arg...)
-	return err
+// GetZpool retrieves a single ZFS zpool by name.
+func GetZpool(name string) (*Zpool, error) {
+	return defaultClient.GetZpool(name)
 }
 
-// zpool is a helper function to wrap typical calls to zpool.
-func zpoolOutput(arg ...string) ([][]string, error) {
-	c := command{Command: "zpool"}
-	return c.Run(arg...)
+// GetZpoolContext is like GetZpool but includes a context.
+func GetZpoolContext(ctx context.Context, name string) (*Zpool, error) {
+	return defaultClient.GetZpoolContext(ctx, name)
 }
 
 // GetZpool retrieves a single ZFS zpool by name.
-func GetZpool(name string) (*Zpool, error) {
-	args := zpoolArgs
-	args = append(args, name)
-	out, err := zpoolOutput(args...)
+func (c *Client) GetZpool(name string) (*Zpool, error) {
+	return c.GetZpoolContext(context.Background(), name)
+}
+
+// GetZpoolContext is like GetZpool but includes a context.
+func (c *Client) GetZpoolContext(ctx context.Context, name string) (*Zpool, error) {
+	out, err := c.getOutput(ctx, c.zpoolPath(), nil, zpoolPropListOptions, name)
 	if err != nil {
 		return nil, err
 	}
 
-	z := &Zpool{Name: name}
+	z := &Zpool{Name: name, client: c}
 	for _, line := range out {
 		if err := z.parseLine(line); err != nil {
 			return nil, err
@@ -60,13 +65,25 @@ func GetZpool(name string) (*Zpool, error) {
 }
 
 // Datasets returns a slice of all ZFS datasets in a zpool.
-func (z *Zpool) Datasets() ([]*Dataset, error) {
-	return Datasets(z.Name)
+// Extra property names may be given to populate the Extra field of each dataset from the same zfs command.
+func (z *Zpool) Datasets(extra ...string) ([]*Dataset, error) {
+	return z.DatasetsContext(context.Background(), extra...)
+}
+
+// DatasetsContext is like Datasets but includes a context.
+func (z *Zpool) DatasetsContext(ctx context.Context, extra ...string) ([]*Dataset, error) {
+	return z.client.DatasetsContext(ctx, z.Name, extra...)
 }
 
 // Snapshots returns a slice of all ZFS snapshots in a zpool.
-func (z *Zpool) Snapshots() ([]*Dataset, error) {
-	return Snapshots(z.Name)
+// Extra property names may be given to populate the Extra field of each snapshot from the same zfs command.
+func (z *Zpool) Snapshots(extra ...string) ([]*Dataset, error) {
+	return z.SnapshotsContext(context.Background(), extra...)
+}
+
+// SnapshotsContext is like Snapshots but includes a context.
+func (z *Zpool) SnapshotsContext(ctx context.Context, extra ...string) ([]*Dataset, error) {
+	return z.client.SnapshotsContext(ctx, z.Name, extra...)
 }
 
 // CreateZpool creates a new ZFS zpool with the specified name, properties, and optional arguments.
@@ -75,6 +92,25 @@ func (z *Zpool) Snapshots() ([]*Dataset, error) {
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
 // https://openzfs.github.io/openzfs-docs/man/8/zpool-create.8.html
 func CreateZpool(name string, properties map[string]string, args ...string) (*Zpool, error) {
+	return defaultClient.CreateZpool(name, properties, args...)
+}
+
+// CreateZpoolContext is like CreateZpool but includes a context.
+func CreateZpoolContext(ctx context.Context, name string, properties map[string]string, args ...string) (*Zpool, error) {
+	return defaultClient.CreateZpoolContext(ctx, name, properties, args...)
+}
+
+// CreateZpool creates a new ZFS zpool with the specified name, properties, and optional arguments.
+//
+// A full list of available ZFS properties and command-line arguments may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-create.8.html
+func (c *Client) CreateZpool(name string, properties map[string]string, args ...string) (*Zpool, error) {
+	return c.CreateZpoolContext(context.Background(), name, properties, args...)
+}
+
+// CreateZpoolContext is like CreateZpool but includes a context.
+func (c *Client) CreateZpoolContext(ctx context.Context, name string, properties map[string]string, args ...string) (*Zpool, error) {
 	cli := make([]string, 1, 4)
 	cli[0] = "create"
 	if properties != nil {
@@ -82,23 +118,52 @@ func CreateZpool(name string, properties map[string]string, args ...string) (*Zp
 	}
 	cli = append(cli, name)
 	cli = append(cli, args...)
-	if err := zpool(cli...); err != nil {
+	if err := c.zpool(ctx, cli...); err != nil {
 		return nil, err
 	}
 
-	return &Zpool{Name: name}, nil
+	return &Zpool{Name: name, client: c}, nil
 }
 
 // Destroy destroys a ZFS zpool by name.
 func (z *Zpool) Destroy() error {
-	err := zpool("destroy", z.Name)
+	return z.DestroyContext(context.Background())
+}
+
+// DestroyContext is like Destroy but includes a context.
+func (z *Zpool) DestroyContext(ctx context.Context) error {
+	err := z.client.zpool(ctx, "destroy", z.Name)
 	return err
 }
 
 // ListZpools list all ZFS zpools accessible on the current system.
 func ListZpools() ([]*Zpool, error) {
-	args := []string{"list", "-Ho", "name"}
-	out, err := zpoolOutput(args...)
+	return defaultClient.ListZpools()
+}
+
+// ListZpoolsContext is like ListZpools but includes a context.
+func ListZpoolsContext(ctx context.Context) ([]*Zpool, error) {
+	return defaultClient.ListZpoolsContext(ctx)
+}
+
+// ListZpools list all ZFS zpools accessible on the current system.
+func (c *Client) ListZpools() ([]*Zpool, error) {
+	return c.ListZpoolsContext(context.Background())
+}
+
+// ListZpoolsContext is like ListZpools but includes a context.
+func (c *Client) ListZpoolsContext(ctx context.Context) ([]*Zpool, error) {
+	var out [][]string
+	var err error
+	if c.useJSON(ctx) {
+		var data []byte
+		data, err = c.jsonOutput(ctx, c.zpoolPath(), "list", "-j", "-o", "name")
+		if err == nil {
+			out, err = jsonListLines(data, "pools", []string{"name"})
+		}
+	} else {
+		out, err = c.zpoolOutput(ctx, "list", "-Ho", "name")
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +171,7 @@ func ListZpools() ([]*Zpool, error) {
 	var pools []*Zpool
 
 	for _, line := range out {
-		z, err := GetZpool(line[0])
+		z, err := c.GetZpoolContext(ctx, line[0])
 		if err != nil {
 			return nil, err
 		}